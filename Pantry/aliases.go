@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"Service"
+)
+
+// ErrAliasNotFound is returned when an alias is not in the registry
+var ErrAliasNotFound = errors.New("alias not found")
+
+// Alias maps another name for an ingredient, such as "scallion", onto its
+// canonical name, such as "green onion". Key is the normalized alias and
+// identifies it, so "Scallions" and "scallion" are the same alias.
+type Alias struct {
+	Key       string    `json:"-" bson:"_id"`
+	Alias     string    `json:"alias" bson:"alias"`
+	Canonical string    `json:"canonical" bson:"canonical"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// aliasRequest is the JSON body of PUT /aliases/{alias}
+type aliasRequest struct {
+	Canonical string `json:"canonical"`
+}
+
+// cleanName trims an ingredient name and collapses runs of spaces
+func cleanName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// resolveIngredient maps the name a request uses onto the ingredient it
+// means. An ingredient stored under exactly that name wins; otherwise an
+// alias is replaced by its canonical name, and a stored ingredient whose
+// name differs only in case, spacing or plural is matched. found reports
+// whether the resolved ingredient is stored.
+func resolveIngredient(ctx context.Context, store PantryStore, name string) (resolved string, found bool, err error) {
+	name = cleanName(name)
+	if name == "" {
+		return "", false, nil
+	}
+	if _, err := store.Get(ctx, name); err == nil {
+		return name, true, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return "", false, err
+	}
+
+	a, err := store.Alias(ctx, service.NormalizeName(name))
+	switch {
+	case err == nil:
+		name = a.Canonical
+		if _, err := store.Get(ctx, name); err == nil {
+			return name, true, nil
+		} else if !errors.Is(err, ErrNotFound) {
+			return "", false, err
+		}
+	case !errors.Is(err, ErrAliasNotFound):
+		return "", false, err
+	}
+
+	// Fall back to the stored spelling of the name
+	match, err := store.Match(ctx, service.NormalizeName(name))
+	switch {
+	case err == nil:
+		return match.Ingredient, true, nil
+	case errors.Is(err, ErrNotFound):
+		return name, false, nil
+	}
+	return "", false, err
+}
+
+// listAliases returns the alias registry, sorted by alias. ?canonical=
+// keeps only the aliases of one ingredient.
+func (db *database) listAliases(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := db.withTimeout(req, db.timeouts.Read)
+	defer cancel()
+
+	aliases, err := db.store.Aliases(ctx)
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	if canonical := req.URL.Query().Get("canonical"); canonical != "" {
+		key := service.NormalizeName(canonical)
+		filtered := []Alias{}
+		for _, a := range aliases {
+			if service.NormalizeName(a.Canonical) == key {
+				filtered = append(filtered, a)
+			}
+		}
+		aliases = filtered
+	}
+
+	writeJSON(w, http.StatusOK, aliases)
+}
+
+// getAlias returns one alias
+func (db *database) getAlias(w http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("alias")
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Read)
+	defer cancel()
+
+	a, err := db.store.Alias(ctx, service.NormalizeName(name))
+	if err != nil {
+		writeAliasError(w, err, name)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, a)
+}
+
+// putAlias adds an alias or points an existing one at a new canonical
+// name. Aliases can't be chained: the canonical name must not itself be an
+// alias, and an alias can't be added for a name other aliases point to.
+func (db *database) putAlias(w http.ResponseWriter, req *http.Request) {
+	name := cleanName(req.PathValue("alias"))
+	var body aliasRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	canonical := cleanName(body.Canonical)
+	key := service.NormalizeName(name)
+	switch {
+	case key == "":
+		writeError(w, http.StatusBadRequest, "invalid_field", "alias is required")
+		return
+	case canonical == "":
+		writeError(w, http.StatusBadRequest, "invalid_field", "canonical is required")
+		return
+	case service.NormalizeName(canonical) == key:
+		writeError(w, http.StatusBadRequest, "invalid_field", "%q is the canonical name, not an alias", name)
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
+	aliases, err := db.store.Aliases(ctx)
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+	for _, a := range aliases {
+		if a.Key == service.NormalizeName(canonical) {
+			writeError(w, http.StatusConflict, "alias_conflict", "%q is itself an alias of %q", canonical, a.Canonical)
+			return
+		}
+		if service.NormalizeName(a.Canonical) == key {
+			writeError(w, http.StatusConflict, "alias_conflict", "%q is the canonical name of the alias %q", name, a.Alias)
+			return
+		}
+	}
+
+	a := Alias{Key: key, Alias: name, Canonical: canonical, CreatedAt: time.Now().UTC()}
+	created, err := db.store.PutAlias(ctx, a)
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, a)
+}
+
+// deleteAlias removes an alias from the registry
+func (db *database) deleteAlias(w http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("alias")
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
+	if err := db.store.DeleteAlias(ctx, service.NormalizeName(name)); err != nil {
+		writeAliasError(w, err, name)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolve reports what a name resolves to, for clients that keep their own
+// ingredient names
+func (db *database) resolve(w http.ResponseWriter, req *http.Request) {
+	name := req.URL.Query().Get("name")
+	if cleanName(name) == "" {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "name is required")
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Read)
+	defer cancel()
+
+	resolved, found, err := resolveIngredient(ctx, pantry(req), name)
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Name      string `json:"name"`
+		Canonical string `json:"canonical"`
+		Stored    bool   `json:"stored"`
+	}{name, resolved, found})
+}
+
+func writeAliasError(w http.ResponseWriter, err error, name string) {
+	if errors.Is(err, ErrAliasNotFound) {
+		writeError(w, http.StatusNotFound, "not_found", "no such alias: %q", name)
+		return
+	}
+	writeStoreError(w, err, name)
+}
+
+// pathIngredient resolves the {name} path parameter of a request. It
+// writes the error response and returns false if the lookup fails.
+func (db *database) pathIngredient(ctx context.Context, w http.ResponseWriter, req *http.Request) (string, bool) {
+	name, _, err := resolveIngredient(ctx, pantry(req), req.PathValue("name"))
+	if err != nil {
+		writeStoreError(w, err, "")
+		return "", false
+	}
+	return name, true
+}
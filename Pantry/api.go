@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxBodyBytes caps the size of JSON request bodies
+const maxBodyBytes = 1 << 20
+
+// apiError is the structured error object returned by the JSON API
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ingredientRequest is the JSON body accepted by POST, PUT and PATCH.
+// Pointer fields distinguish "not sent" from a zero value for PATCH.
+type ingredientRequest struct {
+	Ingredient string   `json:"ingredient"`
+	Price      *Money   `json:"price"`
+	Category   *string  `json:"category"`
+	Quantity   *float64 `json:"quantity"`
+	Unit       *string  `json:"unit"`
+
+	PurchasedAt *flexTime `json:"purchased_at"`
+	ExpiresAt   *flexTime `json:"expires_at"`
+
+	ReorderThreshold *float64 `json:"reorder_threshold"`
+	PurchaseQuantity *float64 `json:"purchase_quantity"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, format string, args ...any) {
+	writeJSON(w, status, struct {
+		Error apiError `json:"error"`
+	}{apiError{Code: code, Message: fmt.Sprintf(format, args...)}})
+}
+
+// decodeBody reads a single JSON object from the request body into v
+func decodeBody(w http.ResponseWriter, req *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "invalid_body", "request body is empty")
+			return false
+		}
+		writeError(w, http.StatusBadRequest, "invalid_body", "invalid JSON body: %v", err)
+		return false
+	}
+	if dec.More() {
+		writeError(w, http.StatusBadRequest, "invalid_body", "request body must contain a single JSON object")
+		return false
+	}
+	return true
+}
+
+// validate checks the fields that were sent in the request body and
+// normalizes the unit
+func (r *ingredientRequest) validate() (string, bool) {
+	if r.Price != nil {
+		if err := validatePrice(*r.Price); err != nil {
+			return err.Error(), false
+		}
+	}
+	if r.Quantity != nil {
+		if err := validateQuantity(*r.Quantity); err != nil {
+			return err.Error(), false
+		}
+	}
+	for name, v := range map[string]*float64{"reorder_threshold": r.ReorderThreshold, "purchase_quantity": r.PurchaseQuantity} {
+		if v != nil {
+			if err := validateQuantity(*v); err != nil {
+				return name + ": " + err.Error(), false
+			}
+		}
+	}
+	if r.Unit != nil {
+		unit, err := normalizeUnit(*r.Unit)
+		if err != nil {
+			return err.Error(), false
+		}
+		r.Unit = &unit
+	}
+	if r.Category != nil {
+		category, err := normalizeCategory(*r.Category)
+		if err != nil {
+			return err.Error(), false
+		}
+		r.Category = &category
+	}
+	if r.PurchasedAt != nil && r.ExpiresAt != nil {
+		purchased, expires := time.Time(*r.PurchasedAt), time.Time(*r.ExpiresAt)
+		if !purchased.IsZero() && !expires.IsZero() && expires.Before(purchased) {
+			return "expires_at must not be before purchased_at", false
+		}
+	}
+	return "", true
+}
+
+// validateNew checks a request that creates an ingredient
+func (r *ingredientRequest) validateNew() (string, bool) {
+	if r.Ingredient == "" {
+		return "ingredient is required", false
+	}
+	if r.Price == nil {
+		return "price is required", false
+	}
+	return r.validate()
+}
+
+// field builds a new ingredient from a validated request
+func (r ingredientRequest) field() Field {
+	f := Field{Ingredient: r.Ingredient}
+	if r.Price != nil {
+		f.Price = *r.Price
+	}
+	if r.Category != nil {
+		f.Category = *r.Category
+	}
+	if r.Quantity != nil {
+		f.Quantity = *r.Quantity
+	}
+	if r.Unit != nil {
+		f.Unit = *r.Unit
+	}
+	if t := r.PurchasedAt.timePtr(); t != nil {
+		f.PurchasedAt = optionalDate(*t)
+	}
+	if t := r.ExpiresAt.timePtr(); t != nil {
+		f.ExpiresAt = optionalDate(*t)
+	}
+	if r.ReorderThreshold != nil {
+		f.ReorderThreshold = *r.ReorderThreshold
+	}
+	if r.PurchaseQuantity != nil {
+		f.PurchaseQuantity = *r.PurchaseQuantity
+	}
+	return f
+}
+
+// fieldUpdate lists the changes a validated request makes to an ingredient
+func (r ingredientRequest) fieldUpdate() FieldUpdate {
+	return FieldUpdate{
+		Price:    r.Price,
+		Category: r.Category,
+		Quantity: r.Quantity,
+		Unit:     r.Unit,
+
+		PurchasedAt: r.PurchasedAt.timePtr(),
+		ExpiresAt:   r.ExpiresAt.timePtr(),
+
+		ReorderThreshold: r.ReorderThreshold,
+		PurchaseQuantity: r.PurchaseQuantity,
+	}
+}
+
+// writeStoreError maps a PantryStore error onto an HTTP status
+func writeStoreError(w http.ResponseWriter, err error, name string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, "not_found", "no such ingredient: %q", name)
+	case errors.Is(err, ErrExists):
+		writeError(w, http.StatusConflict, "already_exists", "ingredient already exists: %s", name)
+	case errors.Is(err, ErrVersionMismatch):
+		writeError(w, http.StatusPreconditionFailed, "precondition_failed", "%s has been modified since the version in If-Match", name)
+	case errors.Is(err, ErrCurrencyMismatch):
+		writeError(w, http.StatusConflict, "currency_mismatch", "%v", err)
+	case isTimeout(err):
+		writeError(w, http.StatusGatewayTimeout, "timeout", "the pantry store did not answer in time")
+	case errors.Is(err, context.Canceled):
+		// The client has gone away, so nobody reads the response
+	default:
+		writeError(w, http.StatusInternalServerError, "internal", "%v", err)
+	}
+}
+
+// listIngredients returns one page of the pantry; see parseListQuery for
+// the parameters
+func (db *database) listIngredients(w http.ResponseWriter, req *http.Request) {
+	filter, err := parseListQuery(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "%v", err)
+		return
+	}
+	fields, err := parseFields(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "%v", err)
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.List)
+	defer cancel()
+
+	total, err := pantry(req).Count(ctx, filter)
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	// Ask for one extra item to find out whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+	ingredients, err := pantry(req).List(ctx, filter)
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	page := listPage{Items: []map[string]json.RawMessage{}, Total: total}
+	if len(ingredients) > limit {
+		ingredients = ingredients[:limit]
+		last := ingredients[limit-1]
+		page.NextPageToken = cursorAfter(last, filter.Sort, filter.Descending).encode()
+	}
+	for _, f := range ingredients {
+		item, err := project(f, fields)
+		if err != nil {
+			writeStoreError(w, err, "")
+			return
+		}
+		page.Items = append(page.Items, item)
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (db *database) getIngredient(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := db.withTimeout(req, db.timeouts.Read)
+	defer cancel()
+
+	name, ok := db.pathIngredient(ctx, w, req)
+	if !ok {
+		return
+	}
+
+	result, err := pantry(req).Get(ctx, name)
+	if err != nil {
+		writeStoreError(w, err, name)
+		return
+	}
+
+	writeField(w, http.StatusOK, result)
+}
+
+func (db *database) createIngredient(w http.ResponseWriter, req *http.Request) {
+	var body ingredientRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	if msg, ok := body.validateNew(); !ok {
+		writeError(w, http.StatusBadRequest, "invalid_field", "%s", msg)
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
+	// Store the ingredient under its canonical name, and refuse other
+	// spellings of an ingredient that is already stored. The store refuses
+	// them as well, which settles concurrent creates.
+	name, found, err := resolveIngredient(ctx, pantry(req), body.Ingredient)
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+	if found {
+		writeStoreError(w, ErrExists, name)
+		return
+	}
+	body.Ingredient = name
+
+	created, err := pantry(req).Create(ctx, body.field())
+	if err != nil {
+		writeStoreError(w, err, body.Ingredient)
+		return
+	}
+
+	w.Header().Set("Location", "/ingredients/"+url.PathEscape(created.Ingredient))
+	writeField(w, http.StatusCreated, created)
+}
+
+// upsertIngredient creates an ingredient, or updates the fields that were
+// sent if it already exists. The price is required, since the ingredient
+// may be created.
+func (db *database) upsertIngredient(w http.ResponseWriter, req *http.Request) {
+	var body ingredientRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	if msg, ok := body.validateNew(); !ok {
+		writeError(w, http.StatusBadRequest, "invalid_field", "%s", msg)
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
+	name, _, err := resolveIngredient(ctx, pantry(req), body.Ingredient)
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+	body.Ingredient = name
+
+	ch, err := pantry(req).Upsert(ctx, body.field(), body.fieldUpdate())
+	if err != nil {
+		writeStoreError(w, err, name)
+		return
+	}
+
+	status := http.StatusOK
+	if ch.Created {
+		w.Header().Set("Location", "/ingredients/"+url.PathEscape(ch.After.Ingredient))
+		status = http.StatusCreated
+	}
+	writeField(w, status, ch.After)
+}
+
+// replaceIngredient handles PUT, which sets every mutable field
+func (db *database) replaceIngredient(w http.ResponseWriter, req *http.Request) {
+	var body ingredientRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	if body.Price == nil {
+		writeError(w, http.StatusBadRequest, "invalid_field", "price is required")
+		return
+	}
+	if body.Category == nil {
+		body.Category = new(string)
+	}
+	if body.Quantity == nil {
+		body.Quantity = new(float64)
+	}
+	if body.Unit == nil {
+		body.Unit = new(string)
+	}
+	if body.PurchasedAt == nil {
+		body.PurchasedAt = new(flexTime)
+	}
+	if body.ExpiresAt == nil {
+		body.ExpiresAt = new(flexTime)
+	}
+	if body.ReorderThreshold == nil {
+		body.ReorderThreshold = new(float64)
+	}
+	if body.PurchaseQuantity == nil {
+		body.PurchaseQuantity = new(float64)
+	}
+	db.updateIngredient(w, req, body)
+}
+
+// patchIngredient handles PATCH, which only sets the fields that were sent
+func (db *database) patchIngredient(w http.ResponseWriter, req *http.Request) {
+	var body ingredientRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	db.updateIngredient(w, req, body)
+}
+
+func (db *database) updateIngredient(w http.ResponseWriter, req *http.Request, body ingredientRequest) {
+	if msg, ok := body.validate(); !ok {
+		writeError(w, http.StatusBadRequest, "invalid_field", "%s", msg)
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
+	name, ok := db.pathIngredient(ctx, w, req)
+	if !ok {
+		return
+	}
+	if body.Ingredient != "" {
+		bodyName, _, err := resolveIngredient(ctx, pantry(req), body.Ingredient)
+		if err != nil {
+			writeStoreError(w, err, "")
+			return
+		}
+		if bodyName != name {
+			writeError(w, http.StatusBadRequest, "invalid_field", "ingredient in body %q does not match path %q", body.Ingredient, req.PathValue("name"))
+			return
+		}
+	}
+
+	// If-Match makes the update fail if someone else changed the item
+	update := body.fieldUpdate()
+	update.IfVersion = ifMatch(req)
+
+	ch, err := pantry(req).Update(ctx, name, update)
+	if err != nil {
+		writeStoreError(w, err, name)
+		return
+	}
+
+	writeField(w, http.StatusOK, ch.After)
+}
+
+func (db *database) deleteIngredient(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
+	name, ok := db.pathIngredient(ctx, w, req)
+	if !ok {
+		return
+	}
+
+	if _, err := pantry(req).Delete(ctx, name, ifMatch(req)); err != nil {
+		writeStoreError(w, err, name)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestAPI serves the pantry API from an empty memory store, with
+// authentication off
+func newTestAPI(t *testing.T) http.Handler {
+	t.Helper()
+	db := &database{store: newMemoryStore(), auth: newAuthenticator(Config{}), timeouts: defaultConfig.Timeouts}
+	return db.routes()
+}
+
+// call makes one request to h. headers are given as name, value pairs.
+func call(h http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeField reads the ingredient in a response body
+func decodeField(t *testing.T, rec *httptest.ResponseRecorder) Field {
+	t.Helper()
+	var f Field
+	if err := json.Unmarshal(rec.Body.Bytes(), &f); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	return f
+}
+
+// errorCode reads the code of a JSON error response
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Error apiError `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	return body.Error.Code
+}
+
+func TestIngredientLifecycle(t *testing.T) {
+	api := newTestAPI(t)
+
+	rec := call(api, "POST", "/ingredients", `{"ingredient": "Flour", "price": "2.50", "quantity": 5, "unit": "kg"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: got %d %s, want 201", rec.Code, rec.Body)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/ingredients/Flour" {
+		t.Errorf("create: Location = %q, want /ingredients/Flour", loc)
+	}
+	if etag := rec.Header().Get("ETag"); etag != `"1"` {
+		t.Errorf("create: ETag = %s, want \"1\"", etag)
+	}
+
+	rec = call(api, "GET", "/ingredients/flour", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get: got %d %s, want 200", rec.Code, rec.Body)
+	}
+	if f := decodeField(t, rec); f.Ingredient != "Flour" || f.Quantity != 5 || f.Price.Decimal() != "2.50" {
+		t.Errorf("get: got %+v", f)
+	}
+
+	rec = call(api, "PATCH", "/ingredients/Flour", `{"quantity": 3}`, "If-Match", `"1"`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch: got %d %s, want 200", rec.Code, rec.Body)
+	}
+	if f := decodeField(t, rec); f.Quantity != 3 || f.Unit != "kg" || f.Version != 2 {
+		t.Errorf("patch: got %+v, want quantity 3 in kg at version 2", f)
+	}
+
+	rec = call(api, "PUT", "/ingredients/Flour", `{"price": "3.00"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("put: got %d %s, want 200", rec.Code, rec.Body)
+	}
+	if f := decodeField(t, rec); f.Quantity != 0 || f.Unit != "" || f.Price.Decimal() != "3.00" {
+		t.Errorf("put: got %+v, want every field but the price cleared", f)
+	}
+
+	rec = call(api, "DELETE", "/ingredients/Flour", "", "If-Match", `"3"`)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: got %d %s, want 204", rec.Code, rec.Body)
+	}
+	if rec = call(api, "GET", "/ingredients/Flour", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete: got %d, want 404", rec.Code)
+	}
+}
+
+func TestIngredientErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		ifMatch string
+		status  int
+		code    string
+	}{
+		{"get missing", "GET", "/ingredients/Sugar", "", "", http.StatusNotFound, "not_found"},
+		{"patch missing", "PATCH", "/ingredients/Sugar", `{"quantity": 1}`, "", http.StatusNotFound, "not_found"},
+		{"put missing", "PUT", "/ingredients/Sugar", `{"price": "1.00"}`, "", http.StatusNotFound, "not_found"},
+		{"delete missing", "DELETE", "/ingredients/Sugar", "", "", http.StatusNotFound, "not_found"},
+		{"create existing", "POST", "/ingredients", `{"ingredient": "Flour", "price": "1.00"}`, "", http.StatusConflict, "already_exists"},
+		{"create other spelling", "POST", "/ingredients", `{"ingredient": "flours", "price": "1.00"}`, "", http.StatusConflict, "already_exists"},
+		{"price in other currency", "PATCH", "/ingredients/Flour", `{"price": "1.00 EUR"}`, "", http.StatusBadRequest, "invalid_field"},
+		{"patch stale", "PATCH", "/ingredients/Flour", `{"quantity": 1}`, `"2"`, http.StatusPreconditionFailed, "precondition_failed"},
+		{"put stale", "PUT", "/ingredients/Flour", `{"price": "1.00"}`, `"0"`, http.StatusPreconditionFailed, "precondition_failed"},
+		{"delete stale", "DELETE", "/ingredients/Flour", "", `"7"`, http.StatusPreconditionFailed, "precondition_failed"},
+		{"foreign etag", "PATCH", "/ingredients/Flour", `{"quantity": 1}`, `"abc"`, http.StatusPreconditionFailed, "precondition_failed"},
+		{"create without price", "POST", "/ingredients", `{"ingredient": "Salt"}`, "", http.StatusBadRequest, "invalid_field"},
+		{"unknown field", "POST", "/ingredients", `{"ingredient": "Salt", "price": "1.00", "colour": "white"}`, "", http.StatusBadRequest, "invalid_body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			if rec := call(api, "POST", "/ingredients", `{"ingredient": "Flour", "price": "2.50"}`); rec.Code != http.StatusCreated {
+				t.Fatalf("create: got %d %s", rec.Code, rec.Body)
+			}
+
+			var headers []string
+			if tt.ifMatch != "" {
+				headers = []string{"If-Match", tt.ifMatch}
+			}
+			rec := call(api, tt.method, tt.path, tt.body, headers...)
+			if rec.Code != tt.status {
+				t.Fatalf("got %d %s, want %d", rec.Code, rec.Body, tt.status)
+			}
+			if code := errorCode(t, rec); code != tt.code {
+				t.Errorf("error code = %q, want %q", code, tt.code)
+			}
+
+			// A failed write leaves the item as it was
+			if f := decodeField(t, call(api, "GET", "/ingredients/Flour", "")); f.Version != 1 {
+				t.Errorf("item changed to version %d", f.Version)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Audit actions
+const (
+	auditCreate   = "create"
+	auditUpdate   = "update"
+	auditDelete   = "delete"
+	auditUndelete = "undelete"
+	auditRestore  = "restore"
+)
+
+// defaultAuditLimit is how many audit entries are returned when the
+// request doesn't say
+const defaultAuditLimit = 100
+
+// AuditEntry records one change to an ingredient. Before is missing for
+// creates and After for deletes.
+type AuditEntry struct {
+	At         time.Time `bson:"at" json:"at"`
+	User       string    `bson:"user" json:"user"`
+	Action     string    `bson:"action" json:"action"`
+	Ingredient string    `bson:"ingredient" json:"ingredient"`
+	Owner      string    `bson:"owner" json:"owner,omitempty"`
+	Household  string    `bson:"household" json:"household,omitempty"`
+	Before     *Field    `bson:"before,omitempty" json:"before,omitempty"`
+	After      *Field    `bson:"after,omitempty" json:"after,omitempty"`
+}
+
+// AuditFilter narrows down the audit log. Zero fields match everything.
+type AuditFilter struct {
+	Ingredient string
+	User       string
+	// Since is inclusive and Until exclusive
+	Since time.Time
+	Until time.Time
+	// Limit caps the number of entries returned; 0 means no limit
+	Limit int
+}
+
+// matches reports whether e passes the filter, ignoring the limit
+func (af AuditFilter) matches(e AuditEntry) bool {
+	return (af.Ingredient == "" || e.Ingredient == af.Ingredient) &&
+		(af.User == "" || e.User == af.User) &&
+		(af.Since.IsZero() || !e.At.Before(af.Since)) &&
+		(af.Until.IsZero() || e.At.Before(af.Until))
+}
+
+// auditedStore records every ingredient change made through it in the
+// audit log, on behalf of one user.
+//
+// The stores return the item as it was before each write, read in the same
+// atomic step, so the entries show exactly what was changed.
+type auditedStore struct {
+	PantryStore
+	user  string
+	scope *Scope
+}
+
+func newAuditedStore(store PantryStore, user string) auditedStore {
+	return auditedStore{PantryStore: store, user: user}
+}
+
+func (s auditedStore) Scoped(sc Scope) PantryStore {
+	return auditedStore{PantryStore: s.PantryStore.Scoped(sc), user: s.user, scope: &sc}
+}
+
+// record appends an entry to the audit log. The change has already been
+// made by then, so a failure is logged rather than returned.
+func (s auditedStore) record(ctx context.Context, action string, before, after *Field) {
+	e := AuditEntry{At: time.Now().UTC(), User: s.user, Action: action, Before: before, After: after}
+	f := after
+	if f == nil {
+		f = before
+	}
+	e.Ingredient, e.Owner, e.Household = f.Ingredient, f.Owner, f.Household
+	if err := s.PantryStore.RecordAudit(ctx, e); err != nil {
+		log.Printf("Recording %s of %q in the audit log: %v", action, e.Ingredient, err)
+	}
+}
+
+// recordChange records a write that may have created the item
+func (s auditedStore) recordChange(ctx context.Context, ch Change) {
+	if ch.Created {
+		s.record(ctx, auditCreate, nil, &ch.After)
+		return
+	}
+	s.record(ctx, auditUpdate, &ch.Before, &ch.After)
+}
+
+func (s auditedStore) Create(ctx context.Context, f Field) (Field, error) {
+	created, err := s.PantryStore.Create(ctx, f)
+	if err != nil {
+		return Field{}, err
+	}
+	s.record(ctx, auditCreate, nil, &created)
+	return created, nil
+}
+
+func (s auditedStore) Update(ctx context.Context, name string, u FieldUpdate) (Change, error) {
+	ch, err := s.PantryStore.Update(ctx, name, u)
+	if err != nil {
+		return Change{}, err
+	}
+	s.recordChange(ctx, ch)
+	return ch, nil
+}
+
+func (s auditedStore) Upsert(ctx context.Context, f Field, u FieldUpdate) (Change, error) {
+	ch, err := s.PantryStore.Upsert(ctx, f, u)
+	if err != nil {
+		return Change{}, err
+	}
+	s.recordChange(ctx, ch)
+	return ch, nil
+}
+
+func (s auditedStore) Put(ctx context.Context, f Field) (bool, error) {
+	// The item is replaced in its own pantry unless the store is scoped
+	sc := Scope{Owner: f.Owner, Household: f.Household}
+	if s.scope != nil {
+		sc = *s.scope
+	}
+	var before *Field
+	if existing, err := s.PantryStore.Scoped(sc).Get(ctx, f.Ingredient); err == nil {
+		before = &existing
+	} else if !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+
+	created, err := s.PantryStore.Put(ctx, f)
+	if err != nil {
+		return false, err
+	}
+	f.Owner, f.Household = sc.Owner, sc.Household
+	s.record(ctx, auditRestore, before, &f)
+	return created, nil
+}
+
+func (s auditedStore) AdjustQuantity(ctx context.Context, name string, delta float64, unit *string, ifVersion *int64) (Change, error) {
+	ch, err := s.PantryStore.AdjustQuantity(ctx, name, delta, unit, ifVersion)
+	if err != nil {
+		return Change{}, err
+	}
+	s.recordChange(ctx, ch)
+	return ch, nil
+}
+
+func (s auditedStore) Delete(ctx context.Context, name string, ifVersion *int64) (Field, error) {
+	before, err := s.PantryStore.Delete(ctx, name, ifVersion)
+	if err != nil {
+		return Field{}, err
+	}
+	s.record(ctx, auditDelete, &before, nil)
+	return before, nil
+}
+
+func (s auditedStore) Restore(ctx context.Context, name string) (Field, error) {
+	restored, err := s.PantryStore.Restore(ctx, name)
+	if err != nil {
+		return Field{}, err
+	}
+	s.record(ctx, auditUndelete, nil, &restored)
+	return restored, nil
+}
+
+// auditLog returns the changes to the pantry, newest first, filtered by
+// ?ingredient=, ?user=, ?since= and ?until=
+func (db *database) auditLog(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	limit, ok := searchLimit(req, defaultAuditLimit)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "limit must be between 1 and %d", maxPageSize)
+		return
+	}
+	filter := AuditFilter{User: query.Get("user"), Limit: limit}
+	for name, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		t, err := parseFlexTime(query.Get(name))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_parameter", "%s: %v", name, err)
+			return
+		}
+		*dst = time.Time(t)
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.List)
+	defer cancel()
+
+	if name := query.Get("ingredient"); name != "" {
+		// Deleted ingredients are looked up under the name given
+		resolved, _, err := resolveIngredient(ctx, pantry(req), name)
+		if err != nil {
+			writeStoreError(w, err, "")
+			return
+		}
+		filter.Ingredient = resolved
+	}
+
+	entries, err := pantry(req).AuditLog(ctx, filter)
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
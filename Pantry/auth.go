@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Household member roles. Viewers can read the household pantry, editors
+// can also change it, and owners can also manage the members.
+const (
+	roleViewer = "viewer"
+	roleEditor = "editor"
+	roleOwner  = "owner"
+)
+
+// roleRank orders the roles from least to most privileged
+var roleRank = map[string]int{roleViewer: 1, roleEditor: 2, roleOwner: 3}
+
+// caller is the authenticated user making a request
+type caller struct {
+	UserID string
+	Admin  bool
+}
+
+type contextKey int
+
+const (
+	callerKey contextKey = iota
+	pantryKey
+)
+
+// authenticator checks API tokens against the configured ones
+type authenticator struct {
+	tokens map[string]string
+	admins map[string]bool
+}
+
+func newAuthenticator(cfg Config) authenticator {
+	a := authenticator{tokens: cfg.Tokens, admins: make(map[string]bool)}
+	for _, admin := range cfg.Admins {
+		a.admins[admin] = true
+	}
+	return a
+}
+
+// enabled reports whether requests have to carry a token
+func (a authenticator) enabled() bool {
+	return len(a.tokens) > 0
+}
+
+// user returns the user ID a bearer token belongs to. Every token is
+// compared, in constant time, so the time taken doesn't leak which one
+// nearly matched.
+func (a authenticator) user(token string) (string, bool) {
+	var userID string
+	for t, u := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			userID = u
+		}
+	}
+	return userID, userID != ""
+}
+
+// known reports whether userID has a token
+func (a authenticator) known(userID string) bool {
+	if !a.enabled() {
+		return true
+	}
+	for _, u := range a.tokens {
+		if u == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate identifies the caller from the Authorization: Bearer header.
+// With authentication off every request comes from an anonymous admin.
+func (db *database) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c := caller{Admin: true}
+		if db.auth.enabled() {
+			token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+			userID, known := db.auth.user(strings.TrimSpace(token))
+			if !ok || !known {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="pantry"`)
+				writeError(w, http.StatusUnauthorized, "unauthorized", "a valid bearer token is required")
+				return
+			}
+			c = caller{UserID: userID, Admin: db.auth.admins[userID]}
+		}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), callerKey, c)))
+	})
+}
+
+// callerOf returns the caller set by authenticate
+func callerOf(req *http.Request) caller {
+	c, _ := req.Context().Value(callerKey).(caller)
+	return c
+}
+
+// scoped wraps a handler that works on one pantry. The pantry is the
+// caller's own unless the X-Household header (or ?household=) names a
+// household, in which case the caller needs at least minRole in it.
+func (db *database) scoped(minRole string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		c := callerOf(req)
+		sc := personalScope(c.UserID)
+
+		id := req.Header.Get("X-Household")
+		if id == "" {
+			id = req.URL.Query().Get("household")
+		}
+		if id != "" {
+			ctx, cancel := db.withTimeout(req, db.timeouts.Read)
+			household, err := db.store.Household(ctx, id)
+			cancel()
+			if err != nil && !errors.Is(err, ErrHouseholdNotFound) {
+				writeStoreError(w, err, "")
+				return
+			}
+			// Non-members can't tell a household they aren't in from one
+			// that doesn't exist
+			role := household.role(c.UserID)
+			if role == "" {
+				writeError(w, http.StatusNotFound, "not_found", "no such household: %q", id)
+				return
+			}
+			if roleRank[role] < roleRank[minRole] {
+				writeError(w, http.StatusForbidden, "forbidden", "%s of household %q can't do this", role, id)
+				return
+			}
+			sc = householdScope(id)
+		}
+
+		// Changes are recorded in the audit log under the caller's name
+		user := c.UserID
+		if user == "" {
+			user = "anonymous"
+		}
+		store := newAuditedStore(db.store, user).Scoped(sc)
+		ctx := context.WithValue(req.Context(), pantryKey, store)
+		h(w, req.WithContext(ctx))
+	}
+}
+
+// pantry returns the store for the pantry a request works on, as chosen
+// by scoped
+func pantry(req *http.Request) PantryStore {
+	return req.Context().Value(pantryKey).(PantryStore)
+}
+
+// admin wraps a handler that only admins may use
+func (db *database) admin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !callerOf(req).Admin {
+			writeError(w, http.StatusForbidden, "forbidden", "only admins can do this")
+			return
+		}
+		h(w, req)
+	}
+}
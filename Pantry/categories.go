@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// categories is the managed list of pantry categories, in display order.
+// An empty category means the item is uncategorized.
+var categories = []string{
+	"produce",
+	"dairy",
+	"meat",
+	"seafood",
+	"bakery",
+	"grains",
+	"baking",
+	"spices",
+	"condiments",
+	"canned",
+	"frozen",
+	"snacks",
+	"beverages",
+	"other",
+}
+
+// categoryGroup is one bucket of the grouped pantry view
+type categoryGroup struct {
+	Category string  `json:"category"`
+	Count    int     `json:"count"`
+	Subtotal Money   `json:"subtotal"`
+	Items    []Field `json:"items"`
+}
+
+// normalizeCategory lower-cases a category and checks it against the
+// managed list
+func normalizeCategory(c string) (string, error) {
+	c = strings.ToLower(strings.TrimSpace(c))
+	if c == "" {
+		return "", nil
+	}
+	for _, known := range categories {
+		if c == known {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("unknown category %q (want one of %s)", c, strings.Join(categories, ", "))
+}
+
+// categoryFilter reads the category query parameters. Categories may be
+// repeated (?category=a&category=b) or comma separated (?category=a,b).
+func categoryFilter(req *http.Request) ([]string, error) {
+	var filter []string
+	for _, v := range req.URL.Query()["category"] {
+		for _, c := range strings.Split(v, ",") {
+			if strings.TrimSpace(c) == "" {
+				continue
+			}
+			category, err := normalizeCategory(c)
+			if err != nil {
+				return nil, err
+			}
+			filter = append(filter, category)
+		}
+	}
+	return filter, nil
+}
+
+// value is what the stocked quantity of an ingredient is worth
+func (f Field) value() Money {
+	return f.Price.Mul(f.Quantity)
+}
+
+func (db *database) listCategories(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, categories)
+}
+
+// categorySummary returns the pantry bucketed by category, in the order of
+// the managed list, with uncategorized items last
+func (db *database) categorySummary(w http.ResponseWriter, req *http.Request) {
+	filter, err := categoryFilter(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "%v", err)
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.List)
+	defer cancel()
+
+	ingredients, err := pantry(req).List(ctx, ListFilter{Categories: filter})
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	buckets := make(map[string]*categoryGroup)
+	for _, f := range ingredients {
+		g, ok := buckets[f.Category]
+		if !ok {
+			g = &categoryGroup{Category: f.Category, Items: []Field{}}
+			buckets[f.Category] = g
+		}
+		g.Items = append(g.Items, f)
+		g.Count++
+		if g.Subtotal, err = g.Subtotal.Add(f.value()); err != nil {
+			writeStoreError(w, err, "")
+			return
+		}
+	}
+
+	groups := []categoryGroup{}
+	for _, c := range categoryOrder(buckets) {
+		groups = append(groups, *buckets[c])
+	}
+
+	writeJSON(w, http.StatusOK, groups)
+}
+
+// categoryOrder returns the categories that have a bucket, in display
+// order: the managed list first, then categories stored before the list
+// was managed, sorted, and the uncategorized items last
+func categoryOrder[G any](buckets map[string]G) []string {
+	order := make([]string, 0, len(buckets))
+	managed := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		managed[c] = true
+		if _, ok := buckets[c]; ok {
+			order = append(order, c)
+		}
+	}
+	var unmanaged []string
+	for c := range buckets {
+		if !managed[c] && c != "" {
+			unmanaged = append(unmanaged, c)
+		}
+	}
+	sort.Strings(unmanaged)
+	order = append(order, unmanaged...)
+	if _, ok := buckets[""]; ok {
+		order = append(order, "")
+	}
+	return order
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCategoryOrder(t *testing.T) {
+	buckets := map[string]bool{"": true, "zz legacy": true, "spices": true, "produce": true, "aa legacy": true}
+	want := []string{"produce", "spices", "aa legacy", "zz legacy", ""}
+	if got := categoryOrder(buckets); !reflect.DeepEqual(got, want) {
+		t.Errorf("categoryOrder = %q, want %q", got, want)
+	}
+	if got := categoryOrder(map[string]bool{}); len(got) != 0 {
+		t.Errorf("categoryOrder of nothing = %q", got)
+	}
+}
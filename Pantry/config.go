@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"Service"
+)
+
+// Config holds the pantry service settings. Values are read, in increasing
+// order of precedence, from the defaults below, the "pantry" section of the
+// shared config file, PANTRY_* environment variables and command-line flags.
+type Config struct {
+	Addr          string           `json:"addr"`
+	Store         string           `json:"store"`
+	MongoURI      string           `json:"mongo_uri"`
+	Database      string           `json:"database"`
+	Currency      string           `json:"currency"`
+	SweepInterval service.Duration `json:"sweep_interval"`
+
+	// TrashRetention is how long deleted items stay in the trash before
+	// they are purged
+	TrashRetention service.Duration `json:"trash_retention"`
+	// Timeouts bounds the time requests may take, by kind of operation
+	Timeouts Timeouts `json:"timeouts"`
+	// DrainGrace is how long the service keeps serving, while reporting
+	// not ready, after it is told to shut down
+	DrainGrace service.Duration `json:"drain_grace"`
+
+	// Tokens maps API tokens to the user IDs they authenticate. With no
+	// tokens, authentication is off and everyone shares one pantry.
+	Tokens map[string]string `json:"tokens"`
+	// Admins are the user IDs allowed to manage the alias registry
+	Admins []string `json:"admins"`
+	// AllowedOrigins are the web pages that may call the autocomplete
+	// endpoint from the browser. The web frontend asks on the server and
+	// doesn't need to be listed.
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+// defaultConfig is used for anything that isn't configured
+var defaultConfig = Config{
+	Addr:          ":9000",
+	Store:         "mongo",
+	MongoURI:      "mongodb://localhost:27017",
+	Database:      "pantry",
+	Currency:      "USD",
+	SweepInterval: service.Duration(time.Hour),
+
+	TrashRetention: service.Duration(30 * 24 * time.Hour),
+	Timeouts: Timeouts{
+		Read:  service.Duration(5 * time.Second),
+		Write: service.Duration(5 * time.Second),
+		List:  service.Duration(10 * time.Second),
+		Bulk:  service.Duration(60 * time.Second),
+	},
+	DrainGrace: service.Duration(service.DefaultDrainGrace),
+}
+
+// loadConfig builds the configuration from every source and validates it.
+// It returns the command-line arguments left after the flags.
+func loadConfig(args []string) (Config, []string, error) {
+	cfg := defaultConfig
+	settings := service.NewSettings("pantry", "pantry", &cfg)
+	fs := settings.Flags()
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: pantry [flags] [serve | import | backup | restore | migrate-money | assign-owner]\n")
+		fs.PrintDefaults()
+	}
+	settings.String(&cfg.Addr, "PANTRY_ADDR", "addr", "address to listen on")
+	settings.String(&cfg.Store, "PANTRY_STORE", "store", "storage backend: mongo or memory")
+	settings.String(&cfg.MongoURI, "PANTRY_MONGO_URI", "mongo", "MongoDB connection URI")
+	settings.String(&cfg.Database, "PANTRY_DATABASE", "database", "MongoDB database name")
+	settings.String(&cfg.Currency, "PANTRY_CURRENCY", "currency", "ISO 4217 currency for prices")
+	settings.Duration(&cfg.SweepInterval, "PANTRY_SWEEP_INTERVAL", "sweep-interval", "how often to mark expired items")
+	settings.Duration(&cfg.TrashRetention, "PANTRY_TRASH_RETENTION", "trash-retention", "how long deleted items stay in the trash")
+	settings.Duration(&cfg.Timeouts.Read, "PANTRY_READ_TIMEOUT", "read-timeout", "time limit for reading single items")
+	settings.Duration(&cfg.Timeouts.Write, "PANTRY_WRITE_TIMEOUT", "write-timeout", "time limit for changes")
+	settings.Duration(&cfg.Timeouts.List, "PANTRY_LIST_TIMEOUT", "list-timeout", "time limit for listings, search and summaries")
+	settings.Duration(&cfg.Timeouts.Bulk, "PANTRY_BULK_TIMEOUT", "bulk-timeout", "time limit for import and export")
+	settings.Duration(&cfg.DrainGrace, "PANTRY_DRAIN_GRACE", "drain-grace", "how long to keep serving, reporting not ready, before shutting down")
+	settings.List(&cfg.AllowedOrigins, "PANTRY_ALLOWED_ORIGINS", "allowed-origins", "comma-separated origins allowed to call autocomplete from the browser")
+	settings.Var("PANTRY_TOKENS", "", "", "", func(v string) error {
+		tokens, err := parseTokens(v)
+		cfg.Tokens = tokens
+		return err
+	})
+	settings.Var("PANTRY_ADMINS", "", "", "", func(v string) error {
+		cfg.Admins = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+		return nil
+	})
+
+	args, err := settings.Load(args)
+	if err != nil {
+		return Config{}, nil, err
+	}
+
+	cfg.Currency = strings.ToUpper(cfg.Currency)
+	for i, origin := range cfg.AllowedOrigins {
+		cfg.AllowedOrigins[i] = strings.TrimSuffix(origin, "/")
+	}
+	if err := cfg.validate(); err != nil {
+		return Config{}, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, args, nil
+}
+
+// parseTokens reads API tokens written as token=user pairs separated by
+// commas
+func parseTokens(s string) (map[string]string, error) {
+	tokens := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		token, user, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("want token=user pairs, got %q", pair)
+		}
+		tokens[strings.TrimSpace(token)] = strings.TrimSpace(user)
+	}
+	return tokens, nil
+}
+
+func (c Config) validate() error {
+	var errs []error
+	if err := service.CheckAddr("addr", c.Addr); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.Store {
+	case "memory":
+	case "mongo":
+		if !strings.HasPrefix(c.MongoURI, "mongodb://") && !strings.HasPrefix(c.MongoURI, "mongodb+srv://") {
+			errs = append(errs, fmt.Errorf("mongo_uri %q must start with mongodb:// or mongodb+srv://", c.MongoURI))
+		}
+		if c.Database == "" {
+			errs = append(errs, errors.New("database must not be empty"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("store %q must be mongo or memory", c.Store))
+	}
+	if _, ok := currencies[c.Currency]; !ok {
+		errs = append(errs, fmt.Errorf("unknown currency %q", c.Currency))
+	}
+	if c.SweepInterval <= 0 {
+		errs = append(errs, fmt.Errorf("sweep_interval must be positive"))
+	}
+	if c.TrashRetention <= 0 {
+		errs = append(errs, fmt.Errorf("trash_retention must be positive"))
+	}
+	if c.DrainGrace < 0 {
+		errs = append(errs, fmt.Errorf("drain_grace must not be negative"))
+	}
+	for name, d := range map[string]service.Duration{
+		"read": c.Timeouts.Read, "write": c.Timeouts.Write, "list": c.Timeouts.List, "bulk": c.Timeouts.Bulk,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("timeouts.%s must be positive", name))
+		}
+	}
+	users := make(map[string]bool)
+	for token, user := range c.Tokens {
+		if token == "" || user == "" {
+			errs = append(errs, errors.New("tokens must map non-empty tokens to non-empty user IDs"))
+			break
+		}
+		users[user] = true
+	}
+	for _, admin := range c.Admins {
+		if len(c.Tokens) > 0 && !users[admin] {
+			errs = append(errs, fmt.Errorf("admin %q has no token", admin))
+		}
+	}
+	for _, origin := range c.AllowedOrigins {
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+			errs = append(errs, fmt.Errorf("allowed_origins: %q must be a scheme and host, such as http://localhost:8080", origin))
+		}
+	}
+	return errors.Join(errs...)
+}
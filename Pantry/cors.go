@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+// cors lets the configured origins read the responses of h from the
+// browser. Other origins get no CORS headers, so the browser keeps the
+// response from them.
+func (db *database) cors(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		if origin := req.Header.Get("Origin"); origin != "" && slices.Contains(db.origins, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, X-Household")
+			w.Header().Set("Access-Control-Max-Age", "600")
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// preflight answers a CORS preflight request; cors adds the headers
+func preflight(w http.ResponseWriter, req *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
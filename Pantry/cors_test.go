@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAutocompleteCORS(t *testing.T) {
+	db := newHouseholdAPI(t, "alice")
+	db.origins = []string{"http://localhost:8080"}
+	api := db.routes()
+
+	tests := []struct {
+		name    string
+		method  string
+		origin  string
+		token   string
+		status  int
+		allowed bool
+	}{
+		{"preflight", "OPTIONS", "http://localhost:8080", "", http.StatusNoContent, true},
+		{"preflight from elsewhere", "OPTIONS", "http://evil.example", "", http.StatusNoContent, false},
+		{"get", "GET", "http://localhost:8080", "tok-alice", http.StatusOK, true},
+		{"get without token", "GET", "http://localhost:8080", "", http.StatusUnauthorized, true},
+		{"get from elsewhere", "GET", "http://evil.example", "tok-alice", http.StatusOK, false},
+		{"get without origin", "GET", "", "tok-alice", http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		headers := []string{"Origin", tt.origin}
+		if tt.token != "" {
+			headers = append(headers, "Authorization", "Bearer "+tt.token)
+		}
+		rec := call(api, tt.method, "/autocomplete?q=fl", "", headers...)
+		if rec.Code != tt.status {
+			t.Errorf("%s: got %d %s, want %d", tt.name, rec.Code, rec.Body, tt.status)
+		}
+		allow := rec.Header().Get("Access-Control-Allow-Origin")
+		if tt.allowed && allow != tt.origin || !tt.allowed && allow != "" {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q", tt.name, allow)
+		}
+	}
+
+	// Other endpoints don't take part in CORS
+	rec := call(api, "GET", "/ingredients", "", "Origin", "http://localhost:8080", "Authorization", "Bearer tok-alice")
+	if allow := rec.Header().Get("Access-Control-Allow-Origin"); allow != "" {
+		t.Errorf("/ingredients: Access-Control-Allow-Origin = %q", allow)
+	}
+}
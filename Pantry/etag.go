@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag renders an ingredient's version as a strong entity tag
+func etag(f Field) string {
+	return `"` + strconv.FormatInt(f.Version, 10) + `"`
+}
+
+// ifMatch reads the If-Match header as the version a write expects. It
+// returns nil when there is no precondition, including "If-Match: *" since
+// the write fails anyway if the ingredient doesn't exist. A tag that isn't
+// one of ours can never match, so it maps to a version no item has.
+func ifMatch(req *http.Request) *int64 {
+	v := strings.TrimSpace(req.Header.Get("If-Match"))
+	if v == "" || v == "*" {
+		return nil
+	}
+
+	// Only one tag is compared; lists of tags aren't used by our clients
+	tag, _, _ := strings.Cut(v, ",")
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+	version, err := strconv.ParseInt(strings.Trim(tag, `"`), 10, 64)
+	if err != nil {
+		version = -1
+	}
+	return &version
+}
+
+// writeField writes an ingredient along with its ETag
+func writeField(w http.ResponseWriter, status int, f Field) {
+	w.Header().Set("ETag", etag(f))
+	writeJSON(w, status, f)
+}
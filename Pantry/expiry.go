@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// dateLayout is the short date format accepted for purchase and expiry dates
+const dateLayout = "2006-01-02"
+
+// flexTime is a time that can be sent either as a date ("2024-05-01") or as
+// an RFC 3339 timestamp. An empty string decodes to the zero time, which
+// clears the date on update.
+type flexTime time.Time
+
+func (t *flexTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("date must be a string: %s", data)
+	}
+	parsed, err := parseFlexTime(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// parseFlexTime parses a date or timestamp; see flexTime
+func parseFlexTime(s string) (flexTime, error) {
+	if s == "" {
+		return flexTime{}, nil
+	}
+	for _, layout := range []string{dateLayout, time.RFC3339} {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			return flexTime(parsed), nil
+		}
+	}
+	return flexTime{}, fmt.Errorf("invalid date %q (want YYYY-MM-DD or RFC 3339)", s)
+}
+
+// timePtr converts an optional request date into an optional stored date
+func (t *flexTime) timePtr() *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := time.Time(*t)
+	return &v
+}
+
+// isExpired reports whether an expiry date has passed at now
+func isExpired(expiresAt *time.Time, now time.Time) bool {
+	return expiresAt != nil && !expiresAt.IsZero() && !expiresAt.After(now)
+}
+
+// optionalDate stores a zero date as "no date"
+func optionalDate(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// expiringItem is an ingredient plus the whole days left until it expires.
+// DaysLeft is negative for items that have already expired.
+type expiringItem struct {
+	Field
+	DaysLeft int `json:"days_left"`
+}
+
+// daysUntil counts calendar days from now until t
+func daysUntil(now, t time.Time) int {
+	y, m, d := now.UTC().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	y, m, d = t.UTC().Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return int(day.Sub(today).Hours() / 24)
+}
+
+// expiring lists the items that expire within the next N days (default 7),
+// most urgent first. Items that have already expired are included.
+func (db *database) expiring(w http.ResponseWriter, req *http.Request) {
+	days := 7
+	if v := req.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid_parameter", "days must be a non-negative integer: %q", v)
+			return
+		}
+		days = n
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.List)
+	defer cancel()
+
+	now := time.Now()
+	ingredients, err := pantry(req).Expiring(ctx, now.AddDate(0, 0, days))
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	items := make([]expiringItem, 0, len(ingredients))
+	for _, f := range ingredients {
+		items = append(items, expiringItem{Field: f, DaysLeft: daysUntil(now, *f.ExpiresAt)})
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// sweepExpired marks expired items every interval until ctx is cancelled
+func sweepExpired(ctx context.Context, store PantryStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sweepCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		n, err := store.MarkExpired(sweepCtx, time.Now())
+		cancel()
+		if err != nil {
+			log.Printf("expiry sweep: %v", err)
+		} else if n > 0 {
+			log.Printf("expiry sweep: marked %d item(s) expired", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// backupVersion is written in the header line of every backup file
+const backupVersion = 1
+
+// backupHeader is the first line of a backup file
+type backupHeader struct {
+	Backup    string    `json:"backup"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// exportWriter writes ingredients one at a time in some file format
+type exportWriter interface {
+	Write(f Field) error
+	Close() error
+}
+
+// newExportWriter returns a writer for format, which is csv, json or ndjson
+func newExportWriter(w io.Writer, format string) (exportWriter, error) {
+	switch format {
+	case "csv":
+		return newCSVExporter(w)
+	case "json":
+		return &jsonExporter{w: w}, nil
+	case "ndjson":
+		return &ndjsonExporter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q (want csv, json or ndjson)", format)
+	}
+}
+
+// exportContentTypes maps export formats to their media types
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv",
+	"json":   "application/json",
+	"ndjson": "application/x-ndjson",
+}
+
+// csvExporter writes the same columns that the CSV import reads
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func newCSVExporter(w io.Writer) (*csvExporter, error) {
+	e := &csvExporter{w: csv.NewWriter(w)}
+	err := e.w.Write([]string{"ingredient", "price", "category", "quantity", "unit", "purchased_at", "expires_at",
+		"reorder_threshold", "purchase_quantity"})
+	return e, err
+}
+
+func (e *csvExporter) Write(f Field) error {
+	date := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	return e.w.Write([]string{
+		f.Ingredient,
+		f.Price.Decimal(),
+		f.Category,
+		strconv.FormatFloat(f.Quantity, 'f', -1, 64),
+		f.Unit,
+		date(f.PurchasedAt),
+		date(f.ExpiresAt),
+		strconv.FormatFloat(f.ReorderThreshold, 'f', -1, 64),
+		strconv.FormatFloat(f.PurchaseQuantity, 'f', -1, 64),
+	})
+}
+
+func (e *csvExporter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonExporter writes a JSON array one element at a time
+type jsonExporter struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonExporter) Write(f Field) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if e.count == 0 {
+		sep = "[\n"
+	}
+	e.count++
+	_, err = fmt.Fprintf(e.w, "%s%s", sep, data)
+	return err
+}
+
+func (e *jsonExporter) Close() error {
+	end := "\n]\n"
+	if e.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// ndjsonExporter writes one JSON object per line
+type ndjsonExporter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExporter) Write(f Field) error { return e.enc.Encode(f) }
+
+func (e *ndjsonExporter) Close() error { return nil }
+
+// export streams the pantry as CSV, JSON or NDJSON (?format=, default json).
+// The category filter of the list endpoint applies.
+func (db *database) export(w http.ResponseWriter, req *http.Request) {
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "format must be csv, json or ndjson: %q", format)
+		return
+	}
+	filter, err := categoryFilter(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "%v", err)
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Bulk)
+	defer cancel()
+
+	// The output is buffered, so nothing is sent before the store has
+	// answered and an early failure still gets an error status. Once rows
+	// have been sent the status can't change, so later errors can only cut
+	// the response short.
+	sent := &sentWriter{ResponseWriter: w}
+	buf := bufio.NewWriter(sent)
+	out, err := newExportWriter(buf, format)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "%v", err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=pantry.%s", format))
+
+	err = pantry(req).Each(ctx, ListFilter{Categories: filter}, out.Write)
+	if err == nil {
+		err = out.Close()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil && !sent.sent {
+		w.Header().Del("Content-Disposition")
+		writeStoreError(w, err, "")
+		return
+	}
+	if err != nil {
+		log.Printf("export: %v", err)
+	}
+}
+
+// sentWriter records whether anything has been written to the response
+type sentWriter struct {
+	http.ResponseWriter
+	sent bool
+}
+
+func (w *sentWriter) Write(p []byte) (int, error) {
+	w.sent = true
+	return w.ResponseWriter.Write(p)
+}
+
+// runBackup implements the backup command:
+//
+//	pantry backup [-o FILE]
+//
+// The backup is an NDJSON file: a header line followed by one ingredient per
+// line, including those in the trash with their deleted_at. It is written to a temporary file and renamed into place, so a
+// failed backup never leaves a partial file behind.
+func runBackup(ctx context.Context, store PantryStore, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	path := fs.String("o", "pantry-backup-"+time.Now().Format("20060102-150405")+".ndjson", "file to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(*path), ".pantry-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	buf := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(buf)
+	if err := enc.Encode(backupHeader{Backup: "pantry", Version: backupVersion, CreatedAt: time.Now()}); err != nil {
+		return err
+	}
+	n := 0
+	err = store.Each(ctx, ListFilter{IncludeTrash: true}, func(f Field) error {
+		n++
+		return enc.Encode(f)
+	})
+	if err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), *path); err != nil {
+		return err
+	}
+
+	fmt.Printf("backed up %d ingredient(s) to %s\n", n, *path)
+	return nil
+}
+
+// runRestore implements the restore command:
+//
+//	pantry restore [-owner ID | -household ID] FILE
+//
+// Every ingredient in the backup replaces the ingredient of the same name,
+// so restoring the same file twice leaves the pantry unchanged. Ingredients
+// that are not in the backup are left alone. Items go back to the pantries
+// they were backed up from, unless -owner or -household moves them all
+// into one pantry.
+func runRestore(ctx context.Context, store PantryStore, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	scope := scopeFlags(fs, nil)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: pantry restore [-owner ID | -household ID] FILE")
+	}
+	store = scope(store)
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	var header backupHeader
+	if err := dec.Decode(&header); err != nil || header.Backup != "pantry" {
+		return fmt.Errorf("%s is not a pantry backup", fs.Arg(0))
+	}
+	if header.Version != backupVersion {
+		return fmt.Errorf("unsupported backup version %d", header.Version)
+	}
+
+	restored, created := 0, 0
+	for line := 2; ; line++ {
+		var field Field
+		if err := dec.Decode(&field); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if field.Ingredient == "" {
+			return fmt.Errorf("line %d: ingredient is missing", line)
+		}
+
+		isNew, err := store.Put(ctx, field)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		restored++
+		if isNew {
+			created++
+		}
+	}
+
+	fmt.Printf("restored %d ingredient(s) from %s (%d new)\n", restored, fs.Arg(0), created)
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingStore fails every Each once it has passed on its first rows items
+type failingStore struct {
+	PantryStore
+	rows int
+}
+
+func (s failingStore) Scoped(sc Scope) PantryStore {
+	return failingStore{s.PantryStore.Scoped(sc), s.rows}
+}
+
+func (s failingStore) Each(ctx context.Context, lf ListFilter, fn func(Field) error) error {
+	n := 0
+	err := s.PantryStore.Each(ctx, lf, func(f Field) error {
+		if n == s.rows {
+			return errors.New("connection reset")
+		}
+		n++
+		return fn(f)
+	})
+	if err == nil {
+		err = errors.New("connection reset")
+	}
+	return err
+}
+
+func TestExportStoreFailure(t *testing.T) {
+	for _, rows := range []int{0, 1} {
+		for _, format := range []string{"csv", "json", "ndjson"} {
+			store := newMemoryStore()
+			for _, name := range []string{"Flour", "Sugar"} {
+				if _, err := store.Create(context.Background(), Field{Ingredient: name, Price: Money{Amount: 100, Currency: "USD"}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			db := &database{store: failingStore{store, rows}, auth: newAuthenticator(Config{}), timeouts: defaultConfig.Timeouts}
+
+			rec := call(db.routes(), "GET", "/export?format="+format, "")
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("%s after %d row(s): got %d %q, want 500", format, rows, rec.Code, rec.Body)
+				continue
+			}
+			if code := errorCode(t, rec); code != "internal" {
+				t.Errorf("%s after %d row(s): error code = %q", format, rows, code)
+			}
+			if rec.Header().Get("Content-Disposition") != "" {
+				t.Errorf("%s after %d row(s): error sent as an attachment", format, rows)
+			}
+		}
+	}
+}
+
+func TestBackupKeepsTrash(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	for _, name := range []string{"Flour", "Sugar"} {
+		if _, err := store.Create(ctx, Field{Ingredient: name, Price: Money{Amount: 100, Currency: "USD"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Delete(ctx, "Sugar", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "pantry.ndjson")
+	if err := runBackup(ctx, store, []string{"-o", path}); err != nil {
+		t.Fatal(err)
+	}
+	restored := newMemoryStore()
+	if err := runRestore(ctx, restored, []string{path}); err != nil {
+		t.Fatal(err)
+	}
+
+	live, err := restored.List(ctx, ListFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(live) != 1 || live[0].Ingredient != "Flour" {
+		t.Errorf("live items = %+v, want Flour", live)
+	}
+	trash, err := restored.Trash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].Ingredient != "Sugar" || trash[0].DeletedAt == nil || time.Since(*trash[0].DeletedAt) > time.Minute {
+		t.Errorf("trash = %+v, want Sugar with its deleted_at", trash)
+	}
+}
+
+// The export writers only see what the store passes them, so trashed
+// items stay out of exports
+func TestExportLeavesOutTrash(t *testing.T) {
+	api := newTestAPI(t)
+	for _, body := range []string{`{"ingredient": "Flour", "price": "1.00"}`, `{"ingredient": "Sugar", "price": "1.00"}`} {
+		if rec := call(api, "POST", "/ingredients", body); rec.Code != http.StatusCreated {
+			t.Fatalf("create: got %d %s", rec.Code, rec.Body)
+		}
+	}
+	if rec := call(api, "DELETE", "/ingredients/Sugar", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: got %d %s", rec.Code, rec.Body)
+	}
+
+	rec := call(api, "GET", "/export?format=csv", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export: got %d %s", rec.Code, rec.Body)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "Flour") || strings.Contains(body, "Sugar") {
+		t.Errorf("export = %q, want Flour without Sugar", body)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// PricePoint is one entry in an ingredient's price history
+type PricePoint struct {
+	Ingredient string    `bson:"ingredient" json:"-"`
+	Owner      string    `bson:"owner" json:"-"`
+	Household  string    `bson:"household" json:"-"`
+	Price      Money     `bson:"price" json:"price"`
+	RecordedAt time.Time `bson:"recorded_at" json:"recorded_at"`
+}
+
+// priceHistory is the response of the price history endpoint
+type priceHistory struct {
+	Ingredient string       `json:"ingredient"`
+	Count      int          `json:"count"`
+	Min        Money        `json:"min"`
+	Max        Money        `json:"max"`
+	Average    Money        `json:"average"`
+	History    []PricePoint `json:"history"`
+}
+
+// newPriceHistory computes the summary statistics for a series of prices.
+// All prices must be in the same currency.
+func newPriceHistory(name string, points []PricePoint) (priceHistory, error) {
+	h := priceHistory{Ingredient: name, Count: len(points), History: points}
+	if len(points) == 0 {
+		return h, nil
+	}
+
+	var sum Money
+	h.Min, h.Max = points[0].Price, points[0].Price
+	for _, p := range points {
+		var err error
+		if sum, err = sum.Add(p.Price); err != nil {
+			return priceHistory{}, err
+		}
+		if p.Price.Cmp(h.Min) < 0 {
+			h.Min = p.Price
+		}
+		if p.Price.Cmp(h.Max) > 0 {
+			h.Max = p.Price
+		}
+	}
+	h.Average = sum.Mul(1 / float64(len(points)))
+	return h, nil
+}
+
+// prices returns the price history of one ingredient, oldest first
+func (db *database) prices(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := db.withTimeout(req, db.timeouts.Read)
+	defer cancel()
+
+	name, ok := db.pathIngredient(ctx, w, req)
+	if !ok {
+		return
+	}
+
+	points, err := pantry(req).PriceHistory(ctx, name)
+	if err != nil {
+		writeStoreError(w, err, name)
+		return
+	}
+
+	// Items created before price history was kept only have their
+	// current price
+	if len(points) == 0 {
+		f, err := pantry(req).Get(ctx, name)
+		if err != nil {
+			writeStoreError(w, err, name)
+			return
+		}
+		points = []PricePoint{{Ingredient: name, Price: f.Price, RecordedAt: f.UpdatedAt}}
+	}
+
+	history, err := newPriceHistory(name, points)
+	if err != nil {
+		writeStoreError(w, err, name)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
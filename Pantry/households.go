@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrHouseholdNotFound is returned when a household does not exist
+	ErrHouseholdNotFound = errors.New("household not found")
+	// ErrMemberNotFound is returned when removing a user who is not a
+	// member of the household
+	ErrMemberNotFound = errors.New("not a member of the household")
+	// ErrLastOwner is returned when a change would leave a household
+	// without an owner
+	ErrLastOwner = errors.New("household needs at least one owner")
+	// ErrHouseholdNotEmpty is returned when deleting a household that
+	// still has ingredients
+	ErrHouseholdNotEmpty = errors.New("household still has ingredients")
+)
+
+// Household is a group of users sharing one pantry
+type Household struct {
+	ID        string    `json:"id" bson:"_id"`
+	Name      string    `json:"name" bson:"name"`
+	Members   []Member  `json:"members" bson:"members"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// Member is a user's membership of a household
+type Member struct {
+	UserID string `json:"user_id" bson:"user_id"`
+	Role   string `json:"role" bson:"role"`
+}
+
+// role returns userID's role in the household, or "" for non-members
+func (h Household) role(userID string) string {
+	for _, m := range h.Members {
+		if m.UserID == userID {
+			return m.Role
+		}
+	}
+	return ""
+}
+
+// owners counts the members with the owner role
+func (h Household) owners() int {
+	n := 0
+	for _, m := range h.Members {
+		if m.Role == roleOwner {
+			n++
+		}
+	}
+	return n
+}
+
+// withMember returns a copy of the household with m added, or with the
+// role of an existing member changed, and reports whether m was new
+func (h Household) withMember(m Member) (Household, bool) {
+	members := append([]Member{}, h.Members...)
+	for i := range members {
+		if members[i].UserID == m.UserID {
+			members[i].Role = m.Role
+			h.Members = members
+			return h, false
+		}
+	}
+	h.Members = append(members, m)
+	return h, true
+}
+
+// withoutMember returns a copy of the household without userID
+func (h Household) withoutMember(userID string) Household {
+	members := []Member{}
+	for _, m := range h.Members {
+		if m.UserID != userID {
+			members = append(members, m)
+		}
+	}
+	h.Members = members
+	return h
+}
+
+// newHouseholdID returns a random household ID
+func newHouseholdID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// memberHousehold loads the {id} household of a request for a caller with
+// at least minRole in it. It writes the error response and returns false
+// otherwise.
+func (db *database) memberHousehold(ctx context.Context, w http.ResponseWriter, req *http.Request, minRole string) (Household, bool) {
+	id := req.PathValue("id")
+	h, err := db.store.Household(ctx, id)
+	if err != nil && !errors.Is(err, ErrHouseholdNotFound) {
+		writeStoreError(w, err, "")
+		return Household{}, false
+	}
+	role := h.role(callerOf(req).UserID)
+	if role == "" {
+		writeError(w, http.StatusNotFound, "not_found", "no such household: %q", id)
+		return Household{}, false
+	}
+	if roleRank[role] < roleRank[minRole] {
+		writeError(w, http.StatusForbidden, "forbidden", "only owners can manage household %q", id)
+		return Household{}, false
+	}
+	return h, true
+}
+
+// listHouseholds returns the households the caller is a member of
+func (db *database) listHouseholds(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := db.withTimeout(req, db.timeouts.Read)
+	defer cancel()
+
+	households, err := db.store.Households(ctx, callerOf(req).UserID)
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, households)
+}
+
+// createHousehold starts a household with the caller as its owner
+func (db *database) createHousehold(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "invalid_field", "name is required")
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
+	h := Household{
+		ID:        newHouseholdID(),
+		Name:      name,
+		Members:   []Member{{UserID: callerOf(req).UserID, Role: roleOwner}},
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := db.store.PutHousehold(ctx, h); err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	w.Header().Set("Location", "/households/"+h.ID)
+	writeJSON(w, http.StatusCreated, h)
+}
+
+func (db *database) getHousehold(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := db.withTimeout(req, db.timeouts.Read)
+	defer cancel()
+
+	h, ok := db.memberHousehold(ctx, w, req, roleViewer)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h)
+}
+
+// deleteHousehold removes a household. Its pantry has to be emptied first,
+// so nobody loses items by accident; whatever is in its trash is purged
+// along with it, since nobody could restore it afterwards.
+func (db *database) deleteHousehold(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
+	h, ok := db.memberHousehold(ctx, w, req, roleOwner)
+	if !ok {
+		return
+	}
+	if err := db.store.DeleteHousehold(ctx, h.ID); err != nil {
+		writeHouseholdError(w, err, h.ID, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// putMember adds a member to a household or changes their role
+func (db *database) putMember(w http.ResponseWriter, req *http.Request) {
+	userID := req.PathValue("user")
+	var body struct {
+		Role string `json:"role"`
+	}
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	if _, ok := roleRank[body.Role]; !ok {
+		writeError(w, http.StatusBadRequest, "invalid_field", "role must be owner, editor or viewer: %q", body.Role)
+		return
+	}
+	if !db.auth.known(userID) {
+		writeError(w, http.StatusBadRequest, "invalid_field", "unknown user %q", userID)
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
+	h, ok := db.memberHousehold(ctx, w, req, roleOwner)
+	if !ok {
+		return
+	}
+
+	updated, created, err := db.store.PutMember(ctx, h.ID, Member{UserID: userID, Role: body.Role})
+	if err != nil {
+		writeHouseholdError(w, err, h.ID, userID)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, updated)
+}
+
+// deleteMember removes a member from a household. Owners can remove
+// anyone; other members can only leave.
+func (db *database) deleteMember(w http.ResponseWriter, req *http.Request) {
+	userID := req.PathValue("user")
+	minRole := roleOwner
+	if userID == callerOf(req).UserID {
+		minRole = roleViewer
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
+	h, ok := db.memberHousehold(ctx, w, req, minRole)
+	if !ok {
+		return
+	}
+
+	if _, err := db.store.RemoveMember(ctx, h.ID, userID); err != nil {
+		writeHouseholdError(w, err, h.ID, userID)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeHouseholdError reports a failed change to the members of household
+// id
+func writeHouseholdError(w http.ResponseWriter, err error, id, userID string) {
+	switch {
+	case errors.Is(err, ErrHouseholdNotFound):
+		writeError(w, http.StatusNotFound, "not_found", "no such household: %q", id)
+	case errors.Is(err, ErrMemberNotFound):
+		writeError(w, http.StatusNotFound, "not_found", "%q is not a member of household %q", userID, id)
+	case errors.Is(err, ErrLastOwner):
+		writeError(w, http.StatusConflict, "last_owner", "household %q needs at least one owner", id)
+	case errors.Is(err, ErrHouseholdNotEmpty):
+		writeError(w, http.StatusConflict, "household_not_empty", "household %q still has ingredients", id)
+	default:
+		writeStoreError(w, err, "")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// newHouseholdAPI returns a pantry service on an empty memory store, with a
+// token "tok-USER" for each user
+func newHouseholdAPI(t *testing.T, users ...string) *database {
+	t.Helper()
+	cfg := Config{Tokens: make(map[string]string)}
+	for _, u := range users {
+		cfg.Tokens["tok-"+u] = u
+	}
+	return &database{store: newMemoryStore(), auth: newAuthenticator(cfg), timeouts: defaultConfig.Timeouts}
+}
+
+// createTestHousehold creates a household owned by owner and returns its ID
+func createTestHousehold(t *testing.T, api http.Handler, owner string) string {
+	t.Helper()
+	rec := call(api, "POST", "/households", `{"name": "Home"}`, "Authorization", "Bearer tok-"+owner)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create household: got %d %s", rec.Code, rec.Body)
+	}
+	var h Household
+	if err := json.Unmarshal(rec.Body.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	return h.ID
+}
+
+func TestHouseholdMembers(t *testing.T) {
+	api := newHouseholdAPI(t, "alice", "bob", "carol").routes()
+	id := createTestHousehold(t, api, "alice")
+	members := "/households/" + id + "/members/"
+
+	tests := []struct {
+		name   string
+		caller string
+		method string
+		user   string
+		body   string
+		status int
+	}{
+		{"add member", "alice", "PUT", "bob", `{"role": "editor"}`, http.StatusCreated},
+		{"change role", "alice", "PUT", "bob", `{"role": "viewer"}`, http.StatusOK},
+		{"not an owner", "bob", "PUT", "carol", `{"role": "viewer"}`, http.StatusForbidden},
+		{"demote last owner", "alice", "PUT", "alice", `{"role": "editor"}`, http.StatusConflict},
+		{"last owner leaves", "alice", "DELETE", "alice", "", http.StatusConflict},
+		{"remove non-member", "alice", "DELETE", "carol", "", http.StatusNotFound},
+		{"second owner", "alice", "PUT", "carol", `{"role": "owner"}`, http.StatusCreated},
+		{"demote one of two owners", "carol", "PUT", "alice", `{"role": "editor"}`, http.StatusOK},
+		{"member leaves", "bob", "DELETE", "bob", "", http.StatusNoContent},
+		{"former member", "bob", "DELETE", "bob", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := call(api, tt.method, members+tt.user, tt.body, "Authorization", "Bearer tok-"+tt.caller)
+		if rec.Code != tt.status {
+			t.Errorf("%s: got %d %s, want %d", tt.name, rec.Code, rec.Body, tt.status)
+		}
+	}
+
+	rec := call(api, "GET", "/households/"+id, "", "Authorization", "Bearer tok-carol")
+	var h Household
+	if err := json.Unmarshal(rec.Body.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.role("alice") != roleEditor || h.role("carol") != roleOwner || h.role("bob") != "" {
+		t.Errorf("members = %+v, want alice editor and carol owner", h.Members)
+	}
+}
+
+func TestHouseholdConcurrentMembers(t *testing.T) {
+	users := []string{"alice"}
+	for i := 0; i < 20; i++ {
+		users = append(users, fmt.Sprintf("user%d", i))
+	}
+	api := newHouseholdAPI(t, users...).routes()
+	id := createTestHousehold(t, api, "alice")
+
+	// Every member added at the same time has to survive
+	var wg sync.WaitGroup
+	for _, u := range users[1:] {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := call(api, "PUT", "/households/"+id+"/members/"+u, `{"role": "viewer"}`, "Authorization", "Bearer tok-alice")
+			if rec.Code != http.StatusCreated {
+				t.Errorf("add %s: got %d %s", u, rec.Code, rec.Body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	rec := call(api, "GET", "/households/"+id, "", "Authorization", "Bearer tok-alice")
+	var h Household
+	if err := json.Unmarshal(rec.Body.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Members) != len(users) {
+		t.Errorf("got %d members, want %d", len(h.Members), len(users))
+	}
+}
+
+func TestDeleteHousehold(t *testing.T) {
+	db := newHouseholdAPI(t, "alice")
+	api := db.routes()
+	id := createTestHousehold(t, api, "alice")
+	auth := []string{"Authorization", "Bearer tok-alice", "X-Household", id}
+
+	if rec := call(api, "POST", "/ingredients", `{"ingredient": "Flour", "price": "2.50"}`, auth...); rec.Code != http.StatusCreated {
+		t.Fatalf("create: got %d %s", rec.Code, rec.Body)
+	}
+	if rec := call(api, "DELETE", "/households/"+id, "", auth...); rec.Code != http.StatusConflict {
+		t.Errorf("delete with items: got %d %s, want 409", rec.Code, rec.Body)
+	}
+	if _, err := db.store.Household(context.Background(), id); err != nil {
+		t.Errorf("household after refused delete: %v", err)
+	}
+	if err := db.store.DeleteHousehold(context.Background(), id); err != ErrHouseholdNotEmpty {
+		t.Errorf("store delete with items: err = %v, want ErrHouseholdNotEmpty", err)
+	}
+
+	// Trashed items don't keep the household alive, and go with it
+	if rec := call(api, "DELETE", "/ingredients/Flour", "", auth...); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete item: got %d %s", rec.Code, rec.Body)
+	}
+	if rec := call(api, "DELETE", "/households/"+id, "", auth...); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete emptied household: got %d %s, want 204", rec.Code, rec.Body)
+	}
+	trash, err := db.store.Scoped(householdScope(id)).Trash(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 0 {
+		t.Errorf("trash of deleted household holds %d item(s)", len(trash))
+	}
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"Service"
+)
+
+// maxImportBytes caps the size of an uploaded import file
+const maxImportBytes = 10 << 20
+
+// Import row statuses
+const (
+	importCreated  = "created"
+	importUpdated  = "updated"
+	importSkipped  = "skipped"
+	importRejected = "rejected"
+)
+
+// importRow is one parsed row of an import file. Row is the CSV line number
+// or the 1-based index in a JSON array.
+type importRow struct {
+	Row int
+	Req ingredientRequest
+	Err error
+}
+
+// importResult reports what happened (or, in a dry run, would happen) to
+// one row
+type importResult struct {
+	Row        int    `json:"row"`
+	Ingredient string `json:"ingredient"`
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+type importReport struct {
+	Mode    string         `json:"mode"`
+	Summary map[string]int `json:"summary"`
+	Rows    []importResult `json:"rows"`
+}
+
+// importer loads rows into the pantry. Each row goes through the same
+// uniqueness check as a single create; rows for ingredients that already
+// exist are skipped, or updated if update is set.
+type importer struct {
+	store  PantryStore
+	commit bool
+	update bool
+}
+
+func (im importer) run(ctx context.Context, rows []importRow) importReport {
+	report := importReport{
+		Mode: "dry-run",
+		Summary: map[string]int{
+			importCreated: 0, importUpdated: 0, importSkipped: 0, importRejected: 0,
+		},
+		Rows: []importResult{},
+	}
+	if im.commit {
+		report.Mode = "commit"
+	}
+
+	seen := make(map[string]int)
+	for _, row := range rows {
+		result := im.importRow(ctx, row, seen)
+		report.Summary[result.Status]++
+		report.Rows = append(report.Rows, result)
+	}
+	return report
+}
+
+func (im importer) importRow(ctx context.Context, row importRow, seen map[string]int) importResult {
+	result := importResult{Row: row.Row, Ingredient: row.Req.Ingredient}
+	reject := func(format string, args ...any) importResult {
+		result.Status, result.Reason = importRejected, fmt.Sprintf(format, args...)
+		return result
+	}
+
+	if row.Err != nil {
+		return reject("%v", row.Err)
+	}
+	req := row.Req
+	if msg, ok := req.validateNew(); !ok {
+		return reject("%s", msg)
+	}
+
+	// Rows are matched to ingredients the same way a single create is
+	name, found, err := resolveIngredient(ctx, im.store, req.Ingredient)
+	if err != nil {
+		return reject("%v", err)
+	}
+	req.Ingredient, result.Ingredient = name, name
+	if first, ok := seen[service.NormalizeName(name)]; ok {
+		return reject("duplicate of row %d", first)
+	}
+	seen[service.NormalizeName(name)] = row.Row
+
+	if !im.commit {
+		// Predict the outcome without writing anything
+		switch {
+		case !found:
+			result.Status = importCreated
+		case im.update:
+			result.Status = importUpdated
+		default:
+			result.Status, result.Reason = importSkipped, "ingredient already exists"
+		}
+		return result
+	}
+
+	if im.update {
+		ch, err := im.store.Upsert(ctx, req.field(), req.fieldUpdate())
+		if err != nil {
+			return reject("%v", err)
+		}
+		result.Status = importUpdated
+		if ch.Created {
+			result.Status = importCreated
+		}
+		return result
+	}
+
+	if found {
+		err = ErrExists
+	} else {
+		_, err = im.store.Create(ctx, req.field())
+	}
+	switch {
+	case err == nil:
+		result.Status = importCreated
+	case errors.Is(err, ErrExists):
+		result.Status, result.Reason = importSkipped, "ingredient already exists"
+	default:
+		return reject("%v", err)
+	}
+	return result
+}
+
+// parseImport reads the rows of a CSV or JSON import file. Errors in a
+// single row are kept on the row; the returned error means the file as a
+// whole could not be read.
+func parseImport(r io.Reader, format string) ([]importRow, error) {
+	switch format {
+	case "csv":
+		return parseImportCSV(r)
+	case "json":
+		return parseImportJSON(r)
+	default:
+		return nil, fmt.Errorf("unknown import format %q (want csv or json)", format)
+	}
+}
+
+// parseImportJSON reads an array of objects shaped like the POST
+// /ingredients body
+func parseImportJSON(r io.Reader) ([]importRow, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("import file must be a JSON array: %v", err)
+	}
+
+	rows := make([]importRow, 0, len(raw))
+	for i, msg := range raw {
+		row := importRow{Row: i + 1}
+		dec := json.NewDecoder(bytes.NewReader(msg))
+		dec.DisallowUnknownFields()
+		row.Err = dec.Decode(&row.Req)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseImportCSV reads a CSV file with a header row. The ingredient and
+// price columns are required; category, quantity, unit, purchased_at and
+// expires_at are optional. Empty cells are treated as not given.
+func parseImportCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "ingredient", "price", "category", "quantity", "unit", "purchased_at", "expires_at",
+			"reorder_threshold", "purchase_quantity":
+			columns[name] = i
+		default:
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+	}
+	for _, required := range []string{"ingredient", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header is missing the %s column", required)
+		}
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			rows = append(rows, importRow{Row: parseErr.StartLine, Err: parseErr.Err})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		row := importRow{Row: line}
+		row.Req, row.Err = csvRequest(record, columns)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// csvRequest converts one CSV record into an ingredient request
+func csvRequest(record []string, columns map[string]int) (ingredientRequest, error) {
+	cell := func(name string) (string, bool) {
+		i, ok := columns[name]
+		if !ok || i >= len(record) || strings.TrimSpace(record[i]) == "" {
+			return "", false
+		}
+		return strings.TrimSpace(record[i]), true
+	}
+
+	var req ingredientRequest
+	req.Ingredient, _ = cell("ingredient")
+	if v, ok := cell("price"); ok {
+		price, err := ParseMoney(v, defaultCurrency)
+		if err != nil {
+			return req, err
+		}
+		req.Price = &price
+	}
+	if v, ok := cell("category"); ok {
+		req.Category = &v
+	}
+	for name, dst := range map[string]**float64{
+		"quantity":          &req.Quantity,
+		"reorder_threshold": &req.ReorderThreshold,
+		"purchase_quantity": &req.PurchaseQuantity,
+	} {
+		if v, ok := cell(name); ok {
+			q, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return req, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*dst = &q
+		}
+	}
+	if v, ok := cell("unit"); ok {
+		req.Unit = &v
+	}
+	for name, dst := range map[string]**flexTime{"purchased_at": &req.PurchasedAt, "expires_at": &req.ExpiresAt} {
+		if v, ok := cell(name); ok {
+			t, err := parseFlexTime(v)
+			if err != nil {
+				return req, fmt.Errorf("%s: %v", name, err)
+			}
+			*dst = &t
+		}
+	}
+	return req, nil
+}
+
+// importFormat works out the format of an upload from the format query
+// parameter or the Content-Type header
+func importFormat(req *http.Request) string {
+	if f := req.URL.Query().Get("format"); f != "" {
+		return strings.ToLower(f)
+	}
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		return "csv"
+	}
+	return "json"
+}
+
+// importIngredients handles POST /import. It runs as a dry run unless
+// mode=commit is given; on_conflict=update updates existing ingredients
+// instead of skipping them.
+func (db *database) importIngredients(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	im := importer{store: pantry(req)}
+	switch mode := query.Get("mode"); mode {
+	case "", "dry-run":
+	case "commit":
+		im.commit = true
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "mode must be dry-run or commit: %q", mode)
+		return
+	}
+	switch onConflict := query.Get("on_conflict"); onConflict {
+	case "", "skip":
+	case "update":
+		im.update = true
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "on_conflict must be skip or update: %q", onConflict)
+		return
+	}
+
+	rows, err := parseImport(http.MaxBytesReader(w, req.Body, maxImportBytes), importFormat(req))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "%v", err)
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Bulk)
+	defer cancel()
+
+	writeJSON(w, http.StatusOK, im.run(ctx, rows))
+}
+
+// runImport implements the import command:
+//
+//	pantry import [-commit] [-update] [-format csv|json] [-owner ID | -household ID] FILE
+//
+// Without -owner or -household the rows go into the shared pantry used when
+// authentication is off.
+func runImport(ctx context.Context, store PantryStore, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	commit := fs.Bool("commit", false, "write the rows (the default is a dry run)")
+	update := fs.Bool("update", false, "update ingredients that already exist instead of skipping them")
+	format := fs.String("format", "", "file format: csv or json (default from the file extension)")
+	scope := scopeFlags(fs, &Scope{})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: pantry import [-commit] [-update] [-format csv|json] [-owner ID | -household ID] FILE")
+	}
+	store = scope(store)
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rows, err := parseImport(f, *format)
+	if err != nil {
+		return err
+	}
+
+	report := importer{store: store, commit: *commit, update: *update}.run(ctx, rows)
+	for _, r := range report.Rows {
+		if r.Reason != "" {
+			fmt.Printf("row %d %s: %s (%s)\n", r.Row, r.Ingredient, r.Status, r.Reason)
+		} else {
+			fmt.Printf("row %d %s: %s\n", r.Row, r.Ingredient, r.Status)
+		}
+	}
+	fmt.Printf("%s: %d created, %d updated, %d skipped, %d rejected\n", report.Mode,
+		report.Summary[importCreated], report.Summary[importUpdated],
+		report.Summary[importSkipped], report.Summary[importRejected])
+	return nil
+}
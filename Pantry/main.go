@@ -3,88 +3,255 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
-	//"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"Service"
 )
 
-const (
-	mongodbEndpoint = "mongodb://172.17.0.2:27017" // Find this from the Mongo container
-)
-
-type dollars float32
-
-func (d dollars) String() string { return fmt.Sprintf("$%.2f", d) }
-
 type Field struct {
-	Ingredient      string             `bson:"ingredient"`
-	Price     dollars            `bson:"price"`
-	Category  string             `bson:"category"`
-	CreatedAt time.Time          `bson:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at"`
+	Ingredient string    `bson:"ingredient" json:"ingredient"`
+	Owner      string    `bson:"owner" json:"owner,omitempty"`
+	Household  string    `bson:"household" json:"household,omitempty"`
+	Price      Money     `bson:"price" json:"price"`
+	Category   string    `bson:"category" json:"category"`
+	Quantity   float64   `bson:"quantity" json:"quantity"`
+	Unit       string    `bson:"unit" json:"unit"`
+	CreatedAt  time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time `bson:"updated_at" json:"updated_at"`
+
+	// Normalized is the ingredient name folded by service.NormalizeName. It is set
+	// by the store, and no two items of a pantry share it.
+	Normalized string `bson:"normalized" json:"-"`
+
+	// Optional shelf-life dates. Expired is set by the expiry sweep, and
+	// whenever an item is saved with an expiry date in the past.
+	PurchasedAt *time.Time `bson:"purchased_at,omitempty" json:"purchased_at,omitempty"`
+	ExpiresAt   *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	Expired     bool       `bson:"expired" json:"expired"`
+
+	// Restocking. An item goes on the shopping list once its quantity
+	// drops below a non-zero ReorderThreshold; PurchaseQuantity is how
+	// much is usually bought.
+	ReorderThreshold float64 `bson:"reorder_threshold" json:"reorder_threshold"`
+	PurchaseQuantity float64 `bson:"purchase_quantity" json:"purchase_quantity"`
+
+	// DeletedAt is set while the item is in the trash
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+
+	// Version goes up by one on every change and is served as the ETag.
+	// Items saved before versions were kept read as version 0.
+	Version int64 `bson:"version" json:"version"`
 }
 
 type database struct {
-	collection *mongo.Collection
+	store    PantryStore
+	auth     authenticator
+	timeouts Timeouts
+	// origins may call the autocomplete endpoint from the browser
+	origins []string
+
+	// draining is set once the server has started shutting down
+	draining atomic.Bool
 }
 
 func main() {
-	// Connect to MongoDB
+	cfg, args, err := loadConfig(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	defaultCurrency = cfg.Currency
+
+	// Open the pantry store
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongodbEndpoint))
+	store, err := openStore(ctx, cfg)
+	cancel()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "", "serve":
+		err = serve(store, cfg)
+	case "import":
+		err = runImport(context.Background(), newAuditedStore(store, "cli"), args[1:])
+	case "backup":
+		err = runBackup(context.Background(), store, args[1:])
+	case "restore":
+		err = runRestore(context.Background(), newAuditedStore(store, "cli"), args[1:])
+	case "migrate-money":
+		err = migrateMoney(context.Background(), store)
+	case "assign-owner":
+		err = runAssignOwner(context.Background(), store, args[1:])
+	default:
+		err = fmt.Errorf("unknown command %q (want serve, import, backup, restore, migrate-money or assign-owner)", command)
+	}
+
+	// Close the store before exiting, whether the command worked or not
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	if err := store.Close(ctx); err != nil {
+		log.Printf("Closing store: %v", err)
+	}
+	cancel()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer client.Disconnect(ctx)
+}
+
+// serve runs the pantry HTTP service until it gets SIGINT or SIGTERM, then
+// drains it and waits for the requests in flight. The background jobs have
+// stopped by the time it returns, so the store can be closed.
+func serve(store PantryStore, cfg Config) error {
+	db := &database{store: store, auth: newAuthenticator(cfg), timeouts: cfg.Timeouts, origins: cfg.AllowedOrigins}
+
+	// Mark expired items and empty the trash in the background
+	ctx, cancel := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+	jobs.Add(2)
+	go func() {
+		defer jobs.Done()
+		sweepExpired(ctx, store, time.Duration(cfg.SweepInterval))
+	}()
+	go func() {
+		defer jobs.Done()
+		purgeTrash(ctx, store, time.Duration(cfg.SweepInterval), time.Duration(cfg.TrashRetention))
+	}()
+	// Stop them, and wait for them, once the server is done
+	defer jobs.Wait()
+	defer cancel()
 
-	// Select database and collection
-	collection := client.Database("pantry").Collection("ingredients")
-	db := database{collection: collection}
+	// Start server
+	if !db.auth.enabled() {
+		log.Printf("No API tokens configured: authentication is off and everyone shares one pantry")
+	}
+	log.Printf("Server started on %s (%s store)", cfg.Addr, cfg.Store)
+	server := &http.Server{Addr: cfg.Addr, Handler: db.routes()}
+	return service.ListenAndServe(server, &db.draining, time.Duration(cfg.DrainGrace))
+}
 
+// routes returns the handler for the whole service
+func (db *database) routes() http.Handler {
 	// Initialize router
 	router := http.NewServeMux()
 
+	// Every ingredient handler works on one pantry, which the caller must
+	// be allowed to read from or write to
+	viewer := func(h http.HandlerFunc) http.HandlerFunc { return db.scoped(roleViewer, h) }
+	editor := func(h http.HandlerFunc) http.HandlerFunc { return db.scoped(roleEditor, h) }
+
 	// Map the handlers
-	router.HandleFunc("/list", db.list)
-	router.HandleFunc("/price", db.price)
-	router.HandleFunc("/create", db.create)
-	router.HandleFunc("/read", db.read)
-	router.HandleFunc("/update", db.update)
-	router.HandleFunc("/delete", db.delete)
+	router.HandleFunc("/list", viewer(db.list))
+	router.HandleFunc("/price", viewer(db.price))
+	router.HandleFunc("/create", editor(db.create))
+	router.HandleFunc("/read", viewer(db.read))
+	router.HandleFunc("/update", editor(db.update))
+	router.HandleFunc("/delete", editor(db.delete))
+
+	// JSON REST API
+	router.HandleFunc("GET /ingredients", viewer(db.listIngredients))
+	router.HandleFunc("POST /ingredients", editor(db.createIngredient))
+	router.HandleFunc("POST /ingredients/upsert", editor(db.upsertIngredient))
+	router.HandleFunc("GET /ingredients/{name}", viewer(db.getIngredient))
+	router.HandleFunc("PUT /ingredients/{name}", editor(db.replaceIngredient))
+	router.HandleFunc("PATCH /ingredients/{name}", editor(db.patchIngredient))
+	router.HandleFunc("DELETE /ingredients/{name}", editor(db.deleteIngredient))
+	router.HandleFunc("POST /ingredients/{name}/add", editor(db.addStock))
+	router.HandleFunc("POST /ingredients/{name}/consume", editor(db.consumeStock))
+	router.HandleFunc("GET /ingredients/{name}/prices", viewer(db.prices))
+	router.HandleFunc("GET /expiring", viewer(db.expiring))
+	router.HandleFunc("GET /categories", viewer(db.listCategories))
+	router.HandleFunc("GET /categories/summary", viewer(db.categorySummary))
+	router.HandleFunc("GET /shopping-list", viewer(db.shoppingList))
+	router.HandleFunc("GET /audit", viewer(db.auditLog))
+	router.HandleFunc("GET /trash", viewer(db.listTrash))
+	router.HandleFunc("POST /trash/{name}/restore", editor(db.restoreIngredient))
+	router.HandleFunc("POST /import", editor(db.importIngredients))
+	router.HandleFunc("GET /export", viewer(db.export))
+	router.HandleFunc("GET /search", viewer(db.search))
+	router.HandleFunc("GET /autocomplete", viewer(db.autocomplete))
+	router.HandleFunc("GET /resolve", viewer(db.resolve))
+
+	// Households and their members
+	router.HandleFunc("GET /households", db.listHouseholds)
+	router.HandleFunc("POST /households", db.createHousehold)
+	router.HandleFunc("GET /households/{id}", db.getHousehold)
+	router.HandleFunc("DELETE /households/{id}", db.deleteHousehold)
+	router.HandleFunc("PUT /households/{id}/members/{user}", db.putMember)
+	router.HandleFunc("DELETE /households/{id}/members/{user}", db.deleteMember)
+
+	// Alias registry administration
+	router.HandleFunc("GET /aliases", db.listAliases)
+	router.HandleFunc("GET /aliases/{alias}", db.getAlias)
+	router.HandleFunc("PUT /aliases/{alias}", db.admin(db.putAlias))
+	router.HandleFunc("DELETE /aliases/{alias}", db.admin(db.deleteAlias))
+
+	// Health checks don't need a token
+	root := http.NewServeMux()
+	root.HandleFunc("GET /healthz", service.Healthz)
+	root.HandleFunc("GET /readyz", service.Readyz(&db.draining, service.Check{Name: "store", Run: db.store.Ping}))
+	api := db.authenticate(router)
+	root.Handle("/", api)
+
+	// The web frontend asks for suggestions from the browser, so
+	// autocomplete answers CORS requests, whose preflight has no token
+	root.Handle("GET /autocomplete", db.cors(api))
+	root.Handle("OPTIONS /autocomplete", db.cors(http.HandlerFunc(preflight)))
+	return root
+}
 
-	// Start server
-	log.Println("Server started on port  9000")
-	log.Fatal(http.ListenAndServe(":9000", router))
+// legacyError answers a failed store call on the legacy endpoints. They
+// reply in plain text, except for timeouts, which get the same 504 as the
+// rest of the API.
+func legacyError(w http.ResponseWriter, err error) {
+	if isTimeout(err) || errors.Is(err, context.Canceled) {
+		writeStoreError(w, err, "")
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// openStore connects to the storage backend selected in the config
+func openStore(ctx context.Context, cfg Config) (PantryStore, error) {
+	switch cfg.Store {
+	case "mongo":
+		return newMongoStore(ctx, cfg.MongoURI, cfg.Database)
+	case "memory":
+		return newMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown store %q (want mongo or memory)", cfg.Store)
+	}
 }
-func (db *database) list(w http.ResponseWriter, r *http.Request) {
-	// Get ingredients from the collection
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
 
-	cursor, err := db.collection.Find(ctx, bson.M{})
+func (db *database) list(w http.ResponseWriter, r *http.Request) {
+	// Get the optional category filter from the query parameters
+	filter, err := categoryFilter(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest) // 400
+		fmt.Fprintf(w, "%v\n", err)
 		return
 	}
-	defer cursor.Close(ctx)
 
-	// Retrieve the ingredients from the cursor
-	var ingredients []Field
-	for cursor.Next(ctx) {
-		var ingredient Field
-		if err := cursor.Decode(&ingredient); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		ingredients = append(ingredients, ingredient)
+	// Get ingredients from the store
+	ctx, cancel := db.withTimeout(r, db.timeouts.List)
+	defer cancel()
+
+	ingredients, err := pantry(r).List(ctx, ListFilter{Categories: filter})
+	if err != nil {
+		legacyError(w, err)
+		return
 	}
 
 	// Write the ingredients
@@ -97,19 +264,24 @@ func (db *database) price(w http.ResponseWriter, req *http.Request) {
 	// Get the ingredient from the query parameter
 	ingredient := req.URL.Query().Get("ingredient")
 
-	// Take ingredient from the database
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Take ingredient from the store
+	ctx, cancel := db.withTimeout(req, db.timeouts.Read)
 	defer cancel()
 
-	var result Field
-	err := db.collection.FindOne(ctx, bson.M{"ingredient": ingredient}).Decode(&result)
+	name, _, err := resolveIngredient(ctx, pantry(req), ingredient)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		legacyError(w, err)
+		return
+	}
+
+	result, err := pantry(req).Get(ctx, name)
+	if err != nil {
+		if err == ErrNotFound {
 			w.WriteHeader(http.StatusNotFound) // 404
 			fmt.Fprintf(w, "no such ingredient: %q\n", ingredient)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		legacyError(w, err)
 		return
 	}
 
@@ -121,45 +293,49 @@ func (db *database) create(w http.ResponseWriter, req *http.Request) {
 	ingredient := req.URL.Query().Get("ingredient")
 	newPrice := req.URL.Query().Get("price")
 
-	price, err := strconv.ParseFloat(newPrice, 32)
+	price, err := ParseMoney(newPrice, defaultCurrency)
 	// Handle Parsing Failure
+	if err == nil {
+		err = validatePrice(price)
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest) // 400
 		fmt.Fprintf(w, "invalid price: %q\n", newPrice)
 		return
 	}
 
-	// Check if ingredient already exists
-	var existingIngredient Field
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	err = db.collection.FindOne(ctx, bson.M{"ingredient": ingredient}).Decode(&existingIngredient)
-	if err == nil {
+	// The category is optional, but must be one of the managed categories
+	category, err := normalizeCategory(req.URL.Query().Get("category"))
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest) // 400
-		fmt.Fprintf(w, "ingredient already exists: %s\n", ingredient)
-		return
-	} else if err != mongo.ErrNoDocuments {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		fmt.Fprintf(w, "%v\n", err)
 		return
 	}
 
-	// Create a new ingredient document
-	newIngredient := Field{
-		Ingredient:      ingredient,
-		Price:     dollars(price),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
+	// Resolve aliases and other spellings to the canonical name
+	ingredient, found, err := resolveIngredient(ctx, pantry(req), ingredient)
+	if err == nil && found {
+		err = ErrExists
 	}
 
-	// Insert the new ingredient document into the collection
-	_, err = db.collection.InsertOne(ctx, newIngredient)
+	// Insert the new ingredient, unless it already exists
+	if err == nil {
+		_, err = pantry(req).Create(ctx, Field{Ingredient: ingredient, Price: price, Category: category})
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err == ErrExists {
+			w.WriteHeader(http.StatusConflict) // 409
+			fmt.Fprintf(w, "ingredient already exists: %s\n", ingredient)
+			return
+		}
+		legacyError(w, err)
 		return
 	}
 
-	fmt.Fprintf(w, "create ingredient: %s, price: %.2f\n", ingredient, price)
+	fmt.Fprintf(w, "create ingredient: %s, price: %s\n", ingredient, price.Decimal())
 }
 
 func (db *database) read(w http.ResponseWriter, req *http.Request) {
@@ -170,67 +346,101 @@ func (db *database) update(w http.ResponseWriter, req *http.Request) {
 	ingredient := req.URL.Query().Get("ingredient")
 	newPrice := req.URL.Query().Get("price")
 
-	price, err := strconv.ParseFloat(newPrice, 32)
+	price, err := ParseMoney(newPrice, defaultCurrency)
 	// Parsing Failure
+	if err == nil {
+		err = validatePrice(price)
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest) // 400
 		fmt.Fprintf(w, "invalid price: %q\n", newPrice)
 		return
 	}
 
-	// Check if ingredient exists
-	var existingIngredient Field
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	update := FieldUpdate{Price: &price, IfVersion: ifMatch(req)}
 
-	err = db.collection.FindOne(ctx, bson.M{"ingredient": ingredient}).Decode(&existingIngredient)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
+	// Only change the category if one was given
+	if req.URL.Query().Has("category") {
+		category, err := normalizeCategory(req.URL.Query().Get("category"))
+		if err != nil {
 			w.WriteHeader(http.StatusBadRequest) // 400
-			fmt.Fprintf(w, "ingredient does not exist: %s\n", ingredient)
+			fmt.Fprintf(w, "%v\n", err)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		update.Category = &category
 	}
 
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
 	// Update ingredient price
-	_, err = db.collection.UpdateOne(ctx,
-		bson.M{"ingredient": ingredient},
-		bson.M{"$set": bson.M{"price": dollars(price), "updated_at": time.Now()}})
+	ingredient, _, err = resolveIngredient(ctx, pantry(req), ingredient)
+	if err == nil {
+		_, err = pantry(req).Update(ctx, ingredient, update)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err == ErrNotFound {
+			w.WriteHeader(http.StatusBadRequest) // 400
+			fmt.Fprintf(w, "ingredient does not exist: %s\n", ingredient)
+			return
+		}
+		if err == ErrVersionMismatch {
+			w.WriteHeader(http.StatusPreconditionFailed) // 412
+			fmt.Fprintf(w, "ingredient has been modified: %s\n", ingredient)
+			return
+		}
+		legacyError(w, err)
 		return
 	}
 
-	fmt.Fprintf(w, "update ingredient: %s, price: %.2f\n", ingredient, price)
+	fmt.Fprintf(w, "update ingredient: %s, price: %s\n", ingredient, price.Decimal())
 }
 
 func (db *database) delete(w http.ResponseWriter, req *http.Request) {
 	ingredient := req.URL.Query().Get("ingredient")
 
-	// Check if ingredient exists
-	var existingIngredient Field
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
 	defer cancel()
 
-	err := db.collection.FindOne(ctx, bson.M{"ingredient": ingredient}).Decode(&existingIngredient)
+	// Delete ingredient from the store
+	ingredient, _, err := resolveIngredient(ctx, pantry(req), ingredient)
+	if err == nil {
+		_, err = pantry(req).Delete(ctx, ingredient, ifMatch(req))
+	}
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		if err == ErrNotFound {
 			w.WriteHeader(http.StatusBadRequest) // 400
 			fmt.Fprintf(w, "ingredient does not exist: %s\n", ingredient)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Delete ingredient from
-	_, err = db.collection.DeleteOne(ctx, bson.M{"ingredient": ingredient})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err == ErrVersionMismatch {
+			w.WriteHeader(http.StatusPreconditionFailed) // 412
+			fmt.Fprintf(w, "ingredient has been modified: %s\n", ingredient)
+			return
+		}
+		legacyError(w, err)
 		return
 	}
 
 	fmt.Fprintf(w, "delete ingredient: %s\n", ingredient)
 }
+
+// scopeFlags adds the -owner and -household flags, which pick the pantry a
+// command works on. The returned function applies them to store; without
+// either flag it scopes store to fallback, or leaves it unscoped if
+// fallback is nil.
+func scopeFlags(fs *flag.FlagSet, fallback *Scope) func(store PantryStore) PantryStore {
+	owner := fs.String("owner", "", "user ID whose personal pantry to use")
+	household := fs.String("household", "", "household ID whose pantry to use")
+	return func(store PantryStore) PantryStore {
+		switch {
+		case *household != "":
+			return store.Scoped(householdScope(*household))
+		case *owner != "":
+			return store.Scoped(personalScope(*owner))
+		case fallback != nil:
+			return store.Scoped(*fallback)
+		}
+		return store
+	}
+}
@@ -0,0 +1,560 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"Service"
+)
+
+// memoryStore keeps the pantry in process memory. It is meant for local
+// development and tests; its contents are lost when the service stops.
+// Scoped views share the same data.
+type memoryStore struct {
+	*memoryData
+	scope *Scope
+}
+
+type memoryData struct {
+	mu          sync.RWMutex
+	ingredients map[itemKey]Field
+	history     map[historyKey][]PricePoint
+	aliases     map[string]Alias
+	households  map[string]Household
+	audit       []AuditEntry
+}
+
+// itemKey identifies an ingredient within its pantry by its normalized
+// name, so a pantry can't hold two spellings of one ingredient
+type itemKey struct {
+	Scope
+	Normalized string
+}
+
+func keyOf(f Field) itemKey {
+	return itemKey{Scope{Owner: f.Owner, Household: f.Household}, service.NormalizeName(f.Ingredient)}
+}
+
+// historyKey identifies the price history of an ingredient name within its
+// pantry
+type historyKey struct {
+	Scope
+	Ingredient string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{memoryData: &memoryData{
+		ingredients: make(map[itemKey]Field),
+		history:     make(map[historyKey][]PricePoint),
+		aliases:     make(map[string]Alias),
+		households:  make(map[string]Household),
+	}}
+}
+
+func (s *memoryStore) Scoped(sc Scope) PantryStore {
+	return &memoryStore{memoryData: s.memoryData, scope: &sc}
+}
+
+// inScope reports whether f is in the store's pantry
+func (s *memoryStore) inScope(f Field) bool {
+	return s.scope == nil || s.scope.Owner == f.Owner && s.scope.Household == f.Household
+}
+
+// live reports whether f is in the store's pantry and not in the trash
+func (s *memoryStore) live(f Field) bool {
+	return s.inScope(f) && f.DeletedAt == nil
+}
+
+// stamp moves f into the store's pantry
+func (s *memoryStore) stamp(f *Field) {
+	if s.scope != nil {
+		f.Owner, f.Household = s.scope.Owner, s.scope.Household
+	}
+}
+
+// lookup finds a live ingredient by name, or with trashed set one in the
+// trash; s.mu must be held. An unscoped store returns the first match from
+// any pantry.
+func (s *memoryStore) lookup(name string, trashed bool) (Field, bool) {
+	f, ok := s.lookupKey(service.NormalizeName(name), trashed)
+	return f, ok && f.Ingredient == name
+}
+
+// lookupKey is lookup by normalized name; s.mu must be held
+func (s *memoryStore) lookupKey(key string, trashed bool) (Field, bool) {
+	if s.scope != nil {
+		f, ok := s.ingredients[itemKey{*s.scope, key}]
+		return f, ok && (f.DeletedAt != nil) == trashed
+	}
+	for k, f := range s.ingredients {
+		if k.Normalized == key && (f.DeletedAt != nil) == trashed {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+func (s *memoryStore) List(ctx context.Context, lf ListFilter) ([]Field, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ingredients := make([]Field, 0, len(s.ingredients))
+	for _, f := range s.ingredients {
+		if !s.inScope(f) || !lf.matches(f) {
+			continue
+		}
+		// Keyset pagination: skip everything up to the cursor
+		if c := lf.After; c != nil {
+			order := compareToCursor(f, c)
+			if lf.Descending && order >= 0 || !lf.Descending && order <= 0 {
+				continue
+			}
+		}
+		ingredients = append(ingredients, f)
+	}
+	sort.Slice(ingredients, func(i, j int) bool {
+		if lf.Descending {
+			return lessBySort(ingredients[j], ingredients[i], lf.Sort)
+		}
+		return lessBySort(ingredients[i], ingredients[j], lf.Sort)
+	})
+	if lf.Limit > 0 && len(ingredients) > lf.Limit {
+		ingredients = ingredients[:lf.Limit]
+	}
+	return ingredients, nil
+}
+
+func (s *memoryStore) Count(ctx context.Context, lf ListFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, f := range s.ingredients {
+		if s.inScope(f) && lf.matches(f) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryStore) Each(ctx context.Context, lf ListFilter, fn func(Field) error) error {
+	// Work on a snapshot so fn can't block writers
+	ingredients, err := s.List(ctx, lf)
+	if err != nil {
+		return err
+	}
+	for _, f := range ingredients {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, name string) (Field, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.lookup(name, false)
+	if !ok {
+		return Field{}, ErrNotFound
+	}
+	return f, nil
+}
+
+func (s *memoryStore) Match(ctx context.Context, key string) (Field, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.lookupKey(key, false)
+	if !ok {
+		return Field{}, ErrNotFound
+	}
+	return f, nil
+}
+
+func (s *memoryStore) Create(ctx context.Context, f Field) (Field, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stamp(&f)
+	if existing, ok := s.ingredients[keyOf(f)]; ok && existing.DeletedAt == nil {
+		return Field{}, ErrExists
+	}
+	return s.insert(f), nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, name string, u FieldUpdate) (Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.lookup(name, false)
+	if !ok {
+		return Change{}, ErrNotFound
+	}
+	if u.IfVersion != nil && *u.IfVersion != f.Version {
+		return Change{}, ErrVersionMismatch
+	}
+	return Change{Before: f, After: s.update(f, u)}, nil
+}
+
+func (s *memoryStore) Upsert(ctx context.Context, f Field, u FieldUpdate) (Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stamp(&f)
+	if existing, ok := s.ingredients[keyOf(f)]; ok && existing.DeletedAt == nil {
+		return Change{Before: existing, After: s.update(existing, u)}, nil
+	}
+	return Change{After: s.insert(f), Created: true}, nil
+}
+
+// insert stores a new ingredient; s.mu must be held
+func (s *memoryStore) insert(f Field) Field {
+	now := time.Now()
+	f.CreatedAt = now
+	f.UpdatedAt = now
+	f.Expired = isExpired(f.ExpiresAt, now)
+	f.Version = 1
+	f.Normalized = service.NormalizeName(f.Ingredient)
+	s.ingredients[keyOf(f)] = f
+	s.recordPrice(f, f.Price, now)
+	return f
+}
+
+// update applies u to f and stores the result; s.mu must be held
+func (s *memoryStore) update(f Field, u FieldUpdate) Field {
+	now := time.Now()
+	if u.Price != nil && *u.Price != f.Price {
+		s.recordPrice(f, *u.Price, now)
+	}
+	u.apply(&f, now)
+	s.ingredients[keyOf(f)] = f
+	return f
+}
+
+func (s *memoryStore) Put(ctx context.Context, f Field) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stamp(&f)
+	f.Normalized = service.NormalizeName(f.Ingredient)
+	_, exists := s.ingredients[keyOf(f)]
+	s.ingredients[keyOf(f)] = f
+	return !exists, nil
+}
+
+func (s *memoryStore) AdjustQuantity(ctx context.Context, name string, delta float64, unit *string, ifVersion *int64) (Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.lookup(name, false)
+	if !ok {
+		return Change{}, ErrNotFound
+	}
+	if unit != nil && *unit != before.Unit {
+		return Change{}, unitMismatch(before, *unit)
+	}
+	if ifVersion != nil && *ifVersion != before.Version {
+		return Change{}, ErrVersionMismatch
+	}
+	if before.Quantity+delta < 0 {
+		return Change{}, ErrInsufficientStock
+	}
+	f := before
+	f.Quantity += delta
+	f.UpdatedAt = time.Now()
+	f.Version++
+	s.ingredients[keyOf(f)] = f
+	return Change{Before: before, After: f}, nil
+}
+
+func (s *memoryStore) Expiring(ctx context.Context, t time.Time) ([]Field, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ingredients := []Field{}
+	for _, f := range s.ingredients {
+		if s.live(f) && f.ExpiresAt != nil && !f.ExpiresAt.After(t) {
+			ingredients = append(ingredients, f)
+		}
+	}
+	sort.Slice(ingredients, func(i, j int) bool {
+		return ingredients[i].ExpiresAt.Before(*ingredients[j].ExpiresAt)
+	})
+	return ingredients, nil
+}
+
+func (s *memoryStore) MarkExpired(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for key, f := range s.ingredients {
+		if s.live(f) && !f.Expired && isExpired(f.ExpiresAt, now) {
+			f.Expired = true
+			f.UpdatedAt = now
+			f.Version++
+			s.ingredients[key] = f
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, name string, ifVersion *int64) (Field, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.lookup(name, false)
+	if !ok {
+		return Field{}, ErrNotFound
+	}
+	if ifVersion != nil && *ifVersion != before.Version {
+		return Field{}, ErrVersionMismatch
+	}
+	now := time.Now()
+	f := before
+	f.DeletedAt = &now
+	f.UpdatedAt = now
+	f.Version++
+	s.ingredients[keyOf(f)] = f
+	return before, nil
+}
+
+func (s *memoryStore) Trash(ctx context.Context) ([]Field, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ingredients := []Field{}
+	for _, f := range s.ingredients {
+		if s.inScope(f) && f.DeletedAt != nil {
+			ingredients = append(ingredients, f)
+		}
+	}
+	sort.Slice(ingredients, func(i, j int) bool {
+		return ingredients[i].DeletedAt.After(*ingredients[j].DeletedAt)
+	})
+	return ingredients, nil
+}
+
+func (s *memoryStore) Restore(ctx context.Context, name string) (Field, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.lookup(name, true)
+	if !ok {
+		return Field{}, ErrNotFound
+	}
+	f.DeletedAt = nil
+	f.UpdatedAt = time.Now()
+	f.Version++
+	s.ingredients[keyOf(f)] = f
+	return f, nil
+}
+
+func (s *memoryStore) Purge(ctx context.Context, t time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for key, f := range s.ingredients {
+		if s.inScope(f) && f.DeletedAt != nil && !f.DeletedAt.After(t) {
+			delete(s.ingredients, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryStore) PriceHistory(ctx context.Context, name string) ([]PricePoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	points := []PricePoint{}
+	for key, history := range s.history {
+		if key.Ingredient == name && (s.scope == nil || key.Scope == *s.scope) {
+			points = append(points, history...)
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].RecordedAt.Before(points[j].RecordedAt) })
+	return points, nil
+}
+
+// recordPrice appends to the price history of f; s.mu must be held
+func (s *memoryStore) recordPrice(f Field, price Money, at time.Time) {
+	key := historyKey{Scope{Owner: f.Owner, Household: f.Household}, f.Ingredient}
+	s.history[key] = append(s.history[key], PricePoint{
+		Ingredient: f.Ingredient, Owner: f.Owner, Household: f.Household, Price: price, RecordedAt: at,
+	})
+}
+
+func (s *memoryStore) Aliases(ctx context.Context) ([]Alias, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	aliases := make([]Alias, 0, len(s.aliases))
+	for _, a := range s.aliases {
+		aliases = append(aliases, a)
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Key < aliases[j].Key })
+	return aliases, nil
+}
+
+func (s *memoryStore) Alias(ctx context.Context, key string) (Alias, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.aliases[key]
+	if !ok {
+		return Alias{}, ErrAliasNotFound
+	}
+	return a, nil
+}
+
+func (s *memoryStore) PutAlias(ctx context.Context, a Alias) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.aliases[a.Key]
+	s.aliases[a.Key] = a
+	return !exists, nil
+}
+
+func (s *memoryStore) DeleteAlias(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.aliases[key]; !ok {
+		return ErrAliasNotFound
+	}
+	delete(s.aliases, key)
+	return nil
+}
+
+func (s *memoryStore) Households(ctx context.Context, userID string) ([]Household, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	households := []Household{}
+	for _, h := range s.households {
+		if h.role(userID) != "" {
+			households = append(households, h)
+		}
+	}
+	sort.Slice(households, func(i, j int) bool { return households[i].Name < households[j].Name })
+	return households, nil
+}
+
+func (s *memoryStore) Household(ctx context.Context, id string) (Household, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok := s.households[id]
+	if !ok {
+		return Household{}, ErrHouseholdNotFound
+	}
+	h.Members = append([]Member{}, h.Members...)
+	return h, nil
+}
+
+func (s *memoryStore) PutHousehold(ctx context.Context, h Household) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h.Members = append([]Member{}, h.Members...)
+	s.households[h.ID] = h
+	return nil
+}
+
+func (s *memoryStore) PutMember(ctx context.Context, id string, m Member) (Household, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.households[id]
+	if !ok {
+		return Household{}, false, ErrHouseholdNotFound
+	}
+	h, created := h.withMember(m)
+	if h.owners() == 0 {
+		return Household{}, false, ErrLastOwner
+	}
+	s.households[id] = h
+	return h, created, nil
+}
+
+func (s *memoryStore) RemoveMember(ctx context.Context, id, userID string) (Household, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.households[id]
+	if !ok {
+		return Household{}, ErrHouseholdNotFound
+	}
+	if h.role(userID) == "" {
+		return Household{}, ErrMemberNotFound
+	}
+	h = h.withoutMember(userID)
+	if h.owners() == 0 {
+		return Household{}, ErrLastOwner
+	}
+	s.households[id] = h
+	return h, nil
+}
+
+func (s *memoryStore) DeleteHousehold(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.households[id]; !ok {
+		return ErrHouseholdNotFound
+	}
+	for _, f := range s.ingredients {
+		if f.Household == id && f.DeletedAt == nil {
+			return ErrHouseholdNotEmpty
+		}
+	}
+	for key, f := range s.ingredients {
+		if f.Household == id {
+			delete(s.ingredients, key)
+		}
+	}
+	delete(s.households, id)
+	return nil
+}
+
+func (s *memoryStore) RecordAudit(ctx context.Context, e AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.audit = append(s.audit, e)
+	return nil
+}
+
+func (s *memoryStore) AuditLog(ctx context.Context, af AuditFilter) ([]AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := []AuditEntry{}
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		e := s.audit[i]
+		if s.scope != nil && (e.Owner != s.scope.Owner || e.Household != s.scope.Household) || !af.matches(e) {
+			continue
+		}
+		entries = append(entries, e)
+		if af.Limit > 0 && len(entries) == af.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+func (s *memoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *memoryStore) Close(ctx context.Context) error {
+	return nil
+}
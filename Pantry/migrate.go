@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// legacyPrice matches prices written by the old float32 dollars type
+var legacyPrice = bson.M{"$type": bson.A{"double", "int", "long", "decimal"}}
+
+// migrateMoney rewrites legacy float prices as Money documents. Legacy
+// prices are read fine without it, but can't be sorted or queried together
+// with migrated ones. It is safe to run more than once.
+func migrateMoney(ctx context.Context, store PantryStore) error {
+	s, ok := store.(*mongoStore)
+	if !ok {
+		return errors.New("migrate-money only applies to the mongo store")
+	}
+
+	for _, collection := range []*mongo.Collection{s.collection, s.history} {
+		n, err := migrateMoneyCollection(ctx, collection)
+		if err != nil {
+			return err
+		}
+		log.Printf("%s: converted %d price(s)", collection.Name(), n)
+	}
+	return nil
+}
+
+func migrateMoneyCollection(ctx context.Context, collection *mongo.Collection) (int, error) {
+	cursor, err := collection.Find(ctx, bson.M{"price": legacyPrice})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	n := 0
+	for cursor.Next(ctx) {
+		// Money decodes the legacy float into the default currency
+		var doc struct {
+			ID    any   `bson:"_id"`
+			Price Money `bson:"price"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return n, err
+		}
+
+		// Only rewrite the price if nobody has changed it in the meantime
+		result, err := collection.UpdateOne(ctx,
+			bson.M{"_id": doc.ID, "price": legacyPrice},
+			bson.M{"$set": bson.M{"price": doc.Price}})
+		if err != nil {
+			return n, err
+		}
+		n += int(result.ModifiedCount)
+	}
+	return n, cursor.Err()
+}
+
+// runAssignOwner moves the items saved before pantries were scoped, which
+// only the shared pantry used with authentication off can reach, into the
+// pantry given by -owner or -household, together with their price history
+// and audit entries. Items that pantry already holds under the same name
+// are left where they are and listed. It is safe to run more than once.
+func runAssignOwner(ctx context.Context, store PantryStore, args []string) error {
+	fs := flag.NewFlagSet("assign-owner", flag.ContinueOnError)
+	owner := fs.String("owner", "", "user ID whose personal pantry gets the items")
+	household := fs.String("household", "", "household ID whose pantry gets the items")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var target Scope
+	switch {
+	case (*owner == "") == (*household == ""):
+		return errors.New("assign-owner needs exactly one of -owner or -household")
+	case *household != "":
+		if _, err := store.Household(ctx, *household); err != nil {
+			return fmt.Errorf("household %q: %w", *household, err)
+		}
+		target = householdScope(*household)
+	default:
+		target = personalScope(*owner)
+	}
+
+	s, ok := store.(*mongoStore)
+	if !ok {
+		return errors.New("assign-owner only applies to the mongo store")
+	}
+
+	shared := bson.M{"owner": "", "household": ""}
+	assign := bson.M{"$set": bson.M{"owner": target.Owner, "household": target.Household}}
+	cursor, err := s.collection.Find(ctx, shared, options.Find().SetProjection(bson.M{"ingredient": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	moved, skipped := 0, 0
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID         any    `bson:"_id"`
+			Ingredient string `bson:"ingredient"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+
+		// The unique index refuses a name the target pantry already has
+		_, err := s.collection.UpdateOne(ctx, bson.M{"_id": doc.ID, "owner": "", "household": ""}, assign)
+		if mongo.IsDuplicateKeyError(err) {
+			log.Printf("%s: already in the target pantry, left in the shared one", doc.Ingredient)
+			skipped++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, c := range []*mongo.Collection{s.history, s.audit} {
+			if _, err := c.UpdateMany(ctx, bson.M{"owner": "", "household": "", "ingredient": doc.Ingredient}, assign); err != nil {
+				return fmt.Errorf("moving %s of %s: %w", c.Name(), doc.Ingredient, err)
+			}
+		}
+		moved++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	log.Printf("Moved %d ingredient(s), skipped %d", moved, skipped)
+	return nil
+}
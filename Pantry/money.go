@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// defaultCurrency is used for prices that are sent without a currency and
+// for legacy prices stored as plain floats
+var defaultCurrency = "USD"
+
+// ErrCurrencyMismatch is returned when combining amounts in different
+// currencies
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// currency describes how amounts in an ISO 4217 currency are written
+type currency struct {
+	Symbol string
+	Digits int // number of minor-unit digits, e.g. 2 for cents
+}
+
+var currencies = map[string]currency{
+	"USD": {Symbol: "$", Digits: 2},
+	"CAD": {Symbol: "CA$", Digits: 2},
+	"AUD": {Symbol: "A$", Digits: 2},
+	"EUR": {Symbol: "€", Digits: 2},
+	"GBP": {Symbol: "£", Digits: 2},
+	"CHF": {Digits: 2},
+	"MXN": {Digits: 2},
+	"JPY": {Symbol: "¥", Digits: 0},
+}
+
+// Money is an exact amount of money, counted in the minor unit (e.g. cents)
+// of its ISO 4217 currency. The zero value is "no amount" and can be added
+// to any currency.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// digits returns the number of minor-unit digits of m's currency
+func (m Money) digits() int {
+	if c, ok := currencies[m.Currency]; ok {
+		return c.Digits
+	}
+	return 2
+}
+
+// Decimal formats m as a plain decimal number, e.g. "2.49"
+func (m Money) Decimal() string {
+	digits := m.digits()
+	amount, sign := m.Amount, ""
+	if amount < 0 {
+		amount, sign = -amount, "-"
+	}
+	if digits == 0 {
+		return sign + strconv.FormatInt(amount, 10)
+	}
+	scale := int64(math.Pow10(digits))
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/scale, digits, amount%scale)
+}
+
+// String formats m for display, e.g. "$2.49" or "12.00 CHF"
+func (m Money) String() string {
+	c, ok := currencies[m.Currency]
+	if m.Currency == "" {
+		return m.Decimal()
+	} else if !ok || c.Symbol == "" {
+		return m.Decimal() + " " + m.Currency
+	}
+	if m.Amount < 0 {
+		return "-" + c.Symbol + Money{Amount: -m.Amount, Currency: m.Currency}.Decimal()
+	}
+	return c.Symbol + m.Decimal()
+}
+
+// IsZero reports whether m is the zero value
+func (m Money) IsZero() bool {
+	return m == Money{}
+}
+
+// Add returns m + o. The zero Money adds to any currency.
+func (m Money) Add(o Money) (Money, error) {
+	switch {
+	case m.IsZero():
+		return o, nil
+	case o.IsZero():
+		return m, nil
+	case m.Currency != o.Currency:
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Mul returns m multiplied by a quantity, rounded to the nearest minor unit
+func (m Money) Mul(q float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * q)), Currency: m.Currency}
+}
+
+// Cmp compares two amounts in the same currency, returning -1, 0 or 1
+func (m Money) Cmp(o Money) int {
+	switch {
+	case m.Amount < o.Amount:
+		return -1
+	case m.Amount > o.Amount:
+		return 1
+	}
+	return 0
+}
+
+// validatePrice checks that a price can be stored in the pantry. Every price
+// is kept in the default currency so totals can be added up.
+func validatePrice(m Money) error {
+	if m.Amount < 0 {
+		return fmt.Errorf("price must not be negative: %s", m)
+	}
+	if m.Currency != defaultCurrency {
+		return fmt.Errorf("price must be in %s, not %s", defaultCurrency, m.Currency)
+	}
+	return nil
+}
+
+// ParseMoney parses an amount such as "2.49", "$2.49", "€3", "2.49 EUR" or
+// "USD 2.49". Amounts without a symbol or code are in currency.
+func ParseMoney(s, currency string) (Money, error) {
+	text := strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(text, "-") {
+		negative, text = true, strings.TrimSpace(text[1:])
+	}
+
+	// Pick the currency from an ISO code or a symbol, if there is one
+	if fields := strings.Fields(text); len(fields) == 2 {
+		if _, ok := currencies[strings.ToUpper(fields[0])]; ok {
+			currency, text = strings.ToUpper(fields[0]), fields[1]
+		} else if _, ok := currencies[strings.ToUpper(fields[1])]; ok {
+			currency, text = strings.ToUpper(fields[1]), fields[0]
+		}
+	}
+	if code, symbol := currencySymbol(text); symbol != "" {
+		currency, text = code, text[len(symbol):]
+	}
+	if strings.HasPrefix(text, "-") && !negative {
+		negative, text = true, text[1:]
+	}
+
+	c, ok := currencies[currency]
+	if !ok {
+		return Money{}, fmt.Errorf("unknown currency %q", currency)
+	}
+
+	whole, frac, hasFrac := strings.Cut(text, ".")
+	if whole == "" && !hasFrac || !isDigits(whole) || hasFrac && (frac == "" || !isDigits(frac)) {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > c.Digits {
+		return Money{}, fmt.Errorf("invalid amount %q: %s has %d decimal places", s, currency, c.Digits)
+	}
+	frac += strings.Repeat("0", c.Digits-len(frac))
+
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// currencySymbol finds the currency symbol that text starts with. Should
+// one symbol be the start of another, as "$" would be of "$U", the longest
+// one that matches wins.
+func currencySymbol(text string) (code, symbol string) {
+	for k, c := range currencies {
+		if c.Symbol != "" && strings.HasPrefix(text, c.Symbol) && len(c.Symbol) > len(symbol) {
+			code, symbol = k, c.Symbol
+		}
+	}
+	return code, symbol
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// moneyDocument is how Money is stored in MongoDB
+type moneyDocument struct {
+	Amount   int64  `bson:"amount"`
+	Currency string `bson:"currency"`
+}
+
+func (m Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(moneyDocument{Amount: m.Amount, Currency: m.Currency})
+}
+
+// UnmarshalBSONValue reads both the current document form and the legacy
+// float form written by the old dollars type, which is taken to be in the
+// default currency.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bson.TypeEmbeddedDocument:
+		var doc moneyDocument
+		if err := raw.Unmarshal(&doc); err != nil {
+			return err
+		}
+		*m = Money{Amount: doc.Amount, Currency: doc.Currency}
+	case bson.TypeDouble:
+		*m = moneyFromFloat(raw.Double())
+	case bson.TypeInt32:
+		*m = moneyFromFloat(float64(raw.Int32()))
+	case bson.TypeInt64:
+		*m = moneyFromFloat(float64(raw.Int64()))
+	case bson.TypeDecimal128:
+		parsed, err := ParseMoney(raw.Decimal128().String(), defaultCurrency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	case bson.TypeNull, bson.TypeUndefined:
+		*m = Money{}
+	default:
+		return fmt.Errorf("cannot decode %s into Money", t)
+	}
+	return nil
+}
+
+// moneyFromFloat converts a legacy float price into the default currency
+func moneyFromFloat(v float64) Money {
+	digits := currencies[defaultCurrency].Digits
+	return Money{Amount: int64(math.Round(v * math.Pow10(digits))), Currency: defaultCurrency}
+}
+
+// moneyJSON is the JSON form of Money. The amount is a decimal string so
+// clients never have to round-trip it through a float.
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency,omitempty"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+// UnmarshalJSON accepts a number (2.49), a string ("$2.49") or an object
+// ({"amount": "2.49", "currency": "EUR"}).
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	currency := defaultCurrency
+	if len(data) > 0 && data[0] == '{' {
+		var obj moneyJSON
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		if obj.Currency != "" {
+			currency = strings.ToUpper(obj.Currency)
+		}
+		data = bytes.TrimSpace(obj.Amount)
+	}
+
+	text := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := ParseMoney(text, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
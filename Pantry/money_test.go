@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+		want     Money
+		wantErr  bool
+	}{
+		{"2.49", "USD", Money{249, "USD"}, false},
+		{"2", "USD", Money{200, "USD"}, false},
+		{"2.5", "USD", Money{250, "USD"}, false},
+		{".75", "USD", Money{75, "USD"}, false},
+		{" 3.10 ", "EUR", Money{310, "EUR"}, false},
+		{"-1.25", "USD", Money{-125, "USD"}, false},
+		{"$2.49", "EUR", Money{249, "USD"}, false},
+		{"-$2.49", "USD", Money{-249, "USD"}, false},
+		{"$-2.49", "USD", Money{-249, "USD"}, false},
+		{"CA$5", "USD", Money{500, "CAD"}, false},
+		{"A$5", "USD", Money{500, "AUD"}, false},
+		{"€3", "USD", Money{300, "EUR"}, false},
+		{"¥500", "USD", Money{500, "JPY"}, false},
+		{"USD 2.49", "EUR", Money{249, "USD"}, false},
+		{"2.49 eur", "USD", Money{249, "EUR"}, false},
+		{"12 CHF", "USD", Money{1200, "CHF"}, false},
+		{"-4.00 GBP", "USD", Money{-400, "GBP"}, false},
+
+		// Amounts are never rounded on the way in
+		{"2.499", "USD", Money{}, true},
+		{"¥1.5", "USD", Money{}, true},
+		{"", "USD", Money{}, true},
+		{".", "USD", Money{}, true},
+		{"2.", "USD", Money{}, true},
+		{"1,000", "USD", Money{}, true},
+		{"--1", "USD", Money{}, true},
+		{"2.49 XYZ", "USD", Money{}, true},
+		{"5", "XYZ", Money{}, true},
+		{"99999999999999999999", "USD", Money{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in, tt.currency)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMoney(%q, %s) error = %v, want error %v", tt.in, tt.currency, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q, %s) = %#v, want %#v", tt.in, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		m       Money
+		decimal string
+		str     string
+	}{
+		{Money{249, "USD"}, "2.49", "$2.49"},
+		{Money{5, "USD"}, "0.05", "$0.05"},
+		{Money{-249, "USD"}, "-2.49", "-$2.49"},
+		{Money{-5, "EUR"}, "-0.05", "-€0.05"},
+		{Money{500, "CAD"}, "5.00", "CA$5.00"},
+		{Money{1200, "CHF"}, "12.00", "12.00 CHF"},
+		{Money{-1200, "MXN"}, "-12.00", "-12.00 MXN"},
+		{Money{500, "JPY"}, "500", "¥500"},
+		{Money{150, ""}, "1.50", "1.50"},
+		{Money{}, "0.00", "0.00"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Decimal(); got != tt.decimal {
+			t.Errorf("%#v.Decimal() = %q, want %q", tt.m, got, tt.decimal)
+		}
+		if got := tt.m.String(); got != tt.str {
+			t.Errorf("%#v.String() = %q, want %q", tt.m, got, tt.str)
+		}
+	}
+}
+
+func TestMoneyRounding(t *testing.T) {
+	tests := []struct {
+		m    Money
+		q    float64
+		want int64
+	}{
+		{Money{249, "USD"}, 3, 747},
+		{Money{199, "USD"}, 0.5, 100}, // 99.5 rounds away from zero
+		{Money{333, "USD"}, 1.0 / 3, 111},
+		{Money{-199, "USD"}, 0.5, -100},
+		{Money{100, "USD"}, 0.004, 0},
+		{Money{100, "USD"}, 0.005, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Mul(tt.q); got.Amount != tt.want || got.Currency != tt.m.Currency {
+			t.Errorf("%v.Mul(%g) = %#v, want %d %s", tt.m, tt.q, got, tt.want, tt.m.Currency)
+		}
+	}
+
+	for v, want := range map[float64]int64{2.49: 249, 0.1 + 0.2: 30, 19.99: 1999, -1.25: -125} {
+		if got := moneyFromFloat(v); got.Amount != want || got.Currency != defaultCurrency {
+			t.Errorf("moneyFromFloat(%v) = %#v, want %d %s", v, got, want, defaultCurrency)
+		}
+	}
+}
+
+func TestMoneyAdd(t *testing.T) {
+	usd, eur := Money{250, "USD"}, Money{100, "EUR"}
+	tests := []struct {
+		a, b    Money
+		want    Money
+		wantErr error
+	}{
+		{usd, Money{-50, "USD"}, Money{200, "USD"}, nil},
+		{usd, Money{}, usd, nil},
+		{Money{}, eur, eur, nil},
+		{usd, eur, Money{}, ErrCurrencyMismatch},
+		{Money{0, "USD"}, eur, Money{}, ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		got, err := tt.a.Add(tt.b)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%#v.Add(%#v) error = %v, want %v", tt.a, tt.b, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%#v.Add(%#v) = %#v, want %#v", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	if err := validatePrice(eur); err == nil {
+		t.Errorf("validatePrice(%v) accepted a price outside %s", eur, defaultCurrency)
+	}
+	if err := validatePrice(Money{-1, defaultCurrency}); err == nil {
+		t.Errorf("validatePrice accepted a negative price")
+	}
+}
@@ -0,0 +1,894 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"Service"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// mongoStore keeps the pantry in the ingredients collection of the
+// configured database (pantry by default). Every pantry shares the
+// collection; documents carry their owner and household.
+type mongoStore struct {
+	client     *mongo.Client
+	collection *mongo.Collection
+	history    *mongo.Collection
+	aliases    *mongo.Collection
+	households *mongo.Collection
+	audit      *mongo.Collection
+
+	// scope limits ingredient queries to one pantry; nil sees them all
+	scope *Scope
+}
+
+func newMongoStore(ctx context.Context, uri, databaseName string) (*mongoStore, error) {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, err
+	}
+
+	// Select database and collections
+	database := client.Database(databaseName)
+	s := &mongoStore{
+		client:     client,
+		collection: database.Collection("ingredients"),
+		history:    database.Collection("price_history"),
+		aliases:    database.Collection("aliases"),
+		households: database.Collection("households"),
+		audit:      database.Collection("audit"),
+	}
+	if err := s.ensureIndexes(ctx); err != nil {
+		client.Disconnect(ctx)
+		return nil, err
+	}
+	return s, nil
+}
+
+// ensureIndexes builds the unique index on normalized ingredient names
+// within a pantry, which is what keeps two concurrent creates of
+// "Tomatoes" and "tomato" from both succeeding. Building it fails if a
+// pantry already holds two spellings of one ingredient.
+//
+// Documents saved before pantries were scoped are first moved into the
+// zero Scope, documents without a normalized name get one, and the older
+// indexes on exact names are dropped.
+func (s *mongoStore) ensureIndexes(ctx context.Context) error {
+	unscoped := bson.M{"owner": bson.M{"$exists": false}}
+	assign := bson.M{"$set": bson.M{"owner": "", "household": ""}}
+	for _, c := range []*mongo.Collection{s.collection, s.history} {
+		if _, err := c.UpdateMany(ctx, unscoped, assign); err != nil {
+			return fmt.Errorf("assigning unscoped %s: %w", c.Name(), err)
+		}
+	}
+
+	if err := s.normalizeNames(ctx); err != nil {
+		return fmt.Errorf("normalizing ingredient names: %w", err)
+	}
+
+	for _, name := range []string{"ingredient_unique", "pantry_ingredient_unique"} {
+		var cmdErr mongo.CommandError
+		if _, err := s.collection.Indexes().DropOne(ctx, name); err != nil &&
+			!(errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27)) { // NamespaceNotFound, IndexNotFound
+			return fmt.Errorf("dropping index %s: %w", name, err)
+		}
+	}
+
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "household", Value: 1}, {Key: "owner", Value: 1}, {Key: "normalized", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("pantry_normalized_unique"),
+	})
+	if err != nil {
+		return fmt.Errorf("building unique index on normalized names (merge ingredients spelled two ways first): %w", err)
+	}
+
+	_, err = s.audit.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "household", Value: 1}, {Key: "owner", Value: 1}, {Key: "at", Value: -1}},
+		Options: options.Index().SetName("pantry_at"),
+	})
+	if err != nil {
+		return fmt.Errorf("building audit log index: %w", err)
+	}
+	return nil
+}
+
+// normalizeNames sets the normalized name of documents saved before it
+// was kept, or folded by an older service.NormalizeName, and moves aliases
+// to their current keys
+func (s *mongoStore) normalizeNames(ctx context.Context) error {
+	cursor, err := s.collection.Find(ctx, bson.M{},
+		options.Find().SetProjection(bson.M{"ingredient": 1, "normalized": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID         any    `bson:"_id"`
+			Ingredient string `bson:"ingredient"`
+			Normalized string `bson:"normalized"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		key := service.NormalizeName(doc.Ingredient)
+		if key == doc.Normalized {
+			continue
+		}
+		_, err := s.collection.UpdateOne(ctx, bson.M{"_id": doc.ID},
+			bson.M{"$set": bson.M{"normalized": key}})
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("%q now matches another item in its pantry; rename or merge them", doc.Ingredient)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	aliases, err := s.Aliases(ctx)
+	if err != nil {
+		return err
+	}
+	for _, a := range aliases {
+		old := a.Key
+		if a.Key = service.NormalizeName(a.Alias); a.Key == old {
+			continue
+		}
+		if _, err := s.PutAlias(ctx, a); err != nil {
+			return err
+		}
+		if err := s.DeleteAlias(ctx, old); err != nil && !errors.Is(err, ErrAliasNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *mongoStore) Scoped(sc Scope) PantryStore {
+	scoped := *s
+	scoped.scope = &sc
+	return &scoped
+}
+
+// inScope limits a query to the store's pantry
+func (s *mongoStore) inScope(filter bson.M) bson.M {
+	if s.scope != nil {
+		filter["owner"] = s.scope.Owner
+		filter["household"] = s.scope.Household
+	}
+	return filter
+}
+
+// live limits a query to the store's pantry and leaves out the trash
+func (s *mongoStore) live(filter bson.M) bson.M {
+	filter["deleted_at"] = nil
+	return s.inScope(filter)
+}
+
+// trashed matches the items in the trash
+func trashed(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$ne": nil}
+	return filter
+}
+
+// discardTrashed removes a trashed item with the normalized name of f from
+// its pantry, so that f can take its place
+func (s *mongoStore) discardTrashed(ctx context.Context, f Field) error {
+	_, err := s.collection.DeleteOne(ctx, trashed(itemFilter(f)))
+	return err
+}
+
+// stamp moves f into the store's pantry and sets its normalized name
+func (s *mongoStore) stamp(f *Field) {
+	if s.scope != nil {
+		f.Owner, f.Household = s.scope.Owner, s.scope.Household
+	}
+	f.Normalized = service.NormalizeName(f.Ingredient)
+}
+
+// itemFilter matches f, or another spelling of it, in its own pantry
+func itemFilter(f Field) bson.M {
+	return bson.M{"normalized": service.NormalizeName(f.Ingredient), "owner": f.Owner, "household": f.Household}
+}
+
+// fieldUpdateDoc translates u into a MongoDB update document
+func fieldUpdateDoc(u FieldUpdate, now time.Time) bson.M {
+	set := bson.M{"updated_at": now}
+	unset := bson.M{}
+	if u.Price != nil {
+		set["price"] = *u.Price
+	}
+	if u.Category != nil {
+		set["category"] = *u.Category
+	}
+	if u.Quantity != nil {
+		set["quantity"] = *u.Quantity
+	}
+	if u.Unit != nil {
+		set["unit"] = *u.Unit
+	}
+	if u.PurchasedAt != nil {
+		if u.PurchasedAt.IsZero() {
+			unset["purchased_at"] = ""
+		} else {
+			set["purchased_at"] = *u.PurchasedAt
+		}
+	}
+	if u.ExpiresAt != nil {
+		if u.ExpiresAt.IsZero() {
+			unset["expires_at"] = ""
+		} else {
+			set["expires_at"] = *u.ExpiresAt
+		}
+		set["expired"] = isExpired(u.ExpiresAt, now)
+	}
+	if u.ReorderThreshold != nil {
+		set["reorder_threshold"] = *u.ReorderThreshold
+	}
+	if u.PurchaseQuantity != nil {
+		set["purchase_quantity"] = *u.PurchaseQuantity
+	}
+
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
+
+// versionFilter matches the named ingredient, and if ifVersion is set only
+// at that version. Documents written before versions were kept have no
+// version field and count as version 0.
+func versionFilter(name string, ifVersion *int64) bson.M {
+	filter := bson.M{"ingredient": name}
+	switch {
+	case ifVersion == nil:
+	case *ifVersion == 0:
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	default:
+		filter["version"] = *ifVersion
+	}
+	return filter
+}
+
+// missOrMismatch tells why a conditional write on name matched nothing
+func (s *mongoStore) missOrMismatch(ctx context.Context, name string) error {
+	if _, err := s.Get(ctx, name); err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
+
+// listFilter translates the category and trash filters of a ListFilter
+// into a MongoDB query
+func listFilter(lf ListFilter) bson.M {
+	filter := bson.M{}
+	if !lf.IncludeTrash {
+		filter["deleted_at"] = nil
+	}
+	if len(lf.Categories) > 0 {
+		filter["category"] = bson.M{"$in": lf.Categories}
+	}
+	return filter
+}
+
+// listSortKey returns the stored key that lf sorts on
+func listSortKey(lf ListFilter) string {
+	switch lf.Sort {
+	case "", "name":
+		return "ingredient"
+	case "price":
+		return "price.amount"
+	default:
+		return sortFields[lf.Sort]
+	}
+}
+
+// listOptions translates the sort and paging of a ListFilter into a query
+// and find options
+func listOptions(lf ListFilter) (bson.M, *options.FindOptions) {
+	filter := listFilter(lf)
+	key := listSortKey(lf)
+	dir, op := 1, "$gt"
+	if lf.Descending {
+		dir, op = -1, "$lt"
+	}
+
+	// Keyset pagination: continue after the cursor's (value, name) pair
+	if c := lf.After; c != nil {
+		if key == "ingredient" {
+			filter["ingredient"] = bson.M{op: c.Ingredient}
+		} else {
+			filter["$or"] = bson.A{
+				bson.M{key: bson.M{op: c.Value}},
+				bson.M{key: c.Value, "ingredient": bson.M{op: c.Ingredient}},
+			}
+		}
+	}
+
+	sort := bson.D{{Key: key, Value: dir}}
+	if key != "ingredient" {
+		sort = append(sort, bson.E{Key: "ingredient", Value: dir})
+	}
+	opts := options.Find().SetSort(sort)
+	if lf.Limit > 0 {
+		opts.SetLimit(int64(lf.Limit))
+	}
+	return filter, opts
+}
+
+func (s *mongoStore) List(ctx context.Context, lf ListFilter) ([]Field, error) {
+	filter, opts := listOptions(lf)
+	cursor, err := s.collection.Find(ctx, s.inScope(filter), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	ingredients := []Field{}
+	if err := cursor.All(ctx, &ingredients); err != nil {
+		return nil, err
+	}
+	return ingredients, nil
+}
+
+func (s *mongoStore) Count(ctx context.Context, lf ListFilter) (int, error) {
+	n, err := s.collection.CountDocuments(ctx, s.inScope(listFilter(lf)))
+	return int(n), err
+}
+
+func (s *mongoStore) Each(ctx context.Context, lf ListFilter, fn func(Field) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "ingredient", Value: 1}})
+	cursor, err := s.collection.Find(ctx, s.inScope(listFilter(lf)), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var f Field
+		if err := cursor.Decode(&f); err != nil {
+			return err
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (s *mongoStore) Get(ctx context.Context, name string) (Field, error) {
+	var result Field
+	err := s.collection.FindOne(ctx, s.live(bson.M{"ingredient": name})).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		return Field{}, ErrNotFound
+	}
+	return result, err
+}
+
+func (s *mongoStore) Match(ctx context.Context, key string) (Field, error) {
+	var result Field
+	err := s.collection.FindOne(ctx, s.live(bson.M{"normalized": key})).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		return Field{}, ErrNotFound
+	}
+	return result, err
+}
+
+func (s *mongoStore) Create(ctx context.Context, f Field) (Field, error) {
+	s.stamp(&f)
+	now := time.Now()
+	f.CreatedAt = now
+	f.UpdatedAt = now
+	f.Expired = isExpired(f.ExpiresAt, now)
+	f.Version = 1
+
+	// The unique index rejects the insert if the ingredient already exists
+	if err := s.discardTrashed(ctx, f); err != nil {
+		return Field{}, err
+	}
+	if _, err := s.collection.InsertOne(ctx, f); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Field{}, ErrExists
+		}
+		return Field{}, err
+	}
+	if err := s.recordPrice(ctx, f, f.Price, now); err != nil {
+		return Field{}, err
+	}
+	return f, nil
+}
+
+func (s *mongoStore) Update(ctx context.Context, name string, u FieldUpdate) (Change, error) {
+	now := time.Now()
+
+	// The document from before the update is returned, and tells whether
+	// the price changed; the one after is worked out from it
+	var before Field
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	filter := s.live(versionFilter(name, u.IfVersion))
+	err := s.collection.FindOneAndUpdate(ctx, filter, fieldUpdateDoc(u, now), opts).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		if u.IfVersion != nil {
+			return Change{}, s.missOrMismatch(ctx, name)
+		}
+		return Change{}, ErrNotFound
+	} else if err != nil {
+		return Change{}, err
+	}
+
+	after := before
+	u.apply(&after, now)
+	if after.Price != before.Price {
+		if err := s.recordPrice(ctx, after, after.Price, now); err != nil {
+			return Change{}, err
+		}
+	}
+	return Change{Before: before, After: after}, nil
+}
+
+func (s *mongoStore) Upsert(ctx context.Context, f Field, u FieldUpdate) (Change, error) {
+	s.stamp(&f)
+	now := time.Now()
+	f.CreatedAt = now
+	f.UpdatedAt = now
+	f.Expired = isExpired(f.ExpiresAt, now)
+	f.Version = 1
+
+	// Fields that u doesn't set are only written when the document is
+	// inserted; the two operators can't name the same field
+	update := fieldUpdateDoc(u, now)
+	onInsert, err := toBSON(f)
+	if err != nil {
+		return Change{}, err
+	}
+	for _, op := range []string{"$set", "$unset", "$inc"} {
+		if fields, ok := update[op].(bson.M); ok {
+			for key := range fields {
+				delete(onInsert, key)
+			}
+		}
+	}
+	for _, key := range []string{"normalized", "owner", "household"} {
+		delete(onInsert, key) // comes from the filter
+	}
+	update["$setOnInsert"] = onInsert
+	if err := s.discardTrashed(ctx, f); err != nil {
+		return Change{}, err
+	}
+
+	var before Field
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	filter := itemFilter(f)
+	err = s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&before)
+	if mongo.IsDuplicateKeyError(err) {
+		// Another upsert inserted the ingredient first; this one now
+		// matches it and updates instead
+		err = s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&before)
+	}
+
+	switch {
+	case err == mongo.ErrNoDocuments:
+		// Nothing matched, so the document was inserted
+		if err := s.recordPrice(ctx, f, f.Price, now); err != nil {
+			return Change{}, err
+		}
+		return Change{After: f, Created: true}, nil
+	case err != nil:
+		return Change{}, err
+	}
+
+	after := before
+	u.apply(&after, now)
+	if after.Price != before.Price {
+		if err := s.recordPrice(ctx, after, after.Price, now); err != nil {
+			return Change{}, err
+		}
+	}
+	return Change{Before: before, After: after}, nil
+}
+
+// toBSON converts a document into a bson.M
+func toBSON(v any) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	err = bson.Unmarshal(data, &doc)
+	return doc, err
+}
+
+func (s *mongoStore) Put(ctx context.Context, f Field) (bool, error) {
+	s.stamp(&f)
+	filter := itemFilter(f)
+	opts := options.Replace().SetUpsert(true)
+	result, err := s.collection.ReplaceOne(ctx, filter, f, opts)
+	if mongo.IsDuplicateKeyError(err) {
+		// Lost a race to insert the same name; replace the winner
+		result, err = s.collection.ReplaceOne(ctx, filter, f, opts)
+	}
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+func (s *mongoStore) AdjustQuantity(ctx context.Context, name string, delta float64, unit *string, ifVersion *int64) (Change, error) {
+	// Only match the document if it is in the expected unit and version
+	// and has enough stock to cover the delta
+	now := time.Now()
+	filter := s.live(versionFilter(name, ifVersion))
+	switch {
+	case unit == nil:
+	case *unit == "":
+		filter["unit"] = bson.M{"$in": bson.A{"", nil}}
+	default:
+		filter["unit"] = *unit
+	}
+	if delta < 0 {
+		filter["quantity"] = bson.M{"$gte": -delta}
+	}
+	update := bson.M{
+		"$inc": bson.M{"quantity": delta, "version": 1},
+		"$set": bson.M{"updated_at": now},
+	}
+
+	var before Field
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		// Tell apart why the ingredient didn't match
+		current, err := s.Get(ctx, name)
+		switch {
+		case err != nil:
+			return Change{}, err
+		case unit != nil && *unit != current.Unit:
+			return Change{}, unitMismatch(current, *unit)
+		case ifVersion != nil && *ifVersion != current.Version:
+			return Change{}, ErrVersionMismatch
+		}
+		return Change{}, ErrInsufficientStock
+	} else if err != nil {
+		return Change{}, err
+	}
+
+	after := before
+	after.Quantity += delta
+	after.UpdatedAt = now
+	after.Version++
+	return Change{Before: before, After: after}, nil
+}
+
+func (s *mongoStore) Expiring(ctx context.Context, t time.Time) ([]Field, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "expires_at", Value: 1}})
+	cursor, err := s.collection.Find(ctx, s.live(bson.M{"expires_at": bson.M{"$lte": t}}), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	ingredients := []Field{}
+	if err := cursor.All(ctx, &ingredients); err != nil {
+		return nil, err
+	}
+	return ingredients, nil
+}
+
+func (s *mongoStore) MarkExpired(ctx context.Context, now time.Time) (int, error) {
+	result, err := s.collection.UpdateMany(ctx,
+		s.live(bson.M{"expires_at": bson.M{"$lte": now}, "expired": bson.M{"$ne": true}}),
+		bson.M{"$set": bson.M{"expired": true, "updated_at": now}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.ModifiedCount), nil
+}
+
+func (s *mongoStore) Delete(ctx context.Context, name string, ifVersion *int64) (Field, error) {
+	now := time.Now()
+	var before Field
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	err := s.collection.FindOneAndUpdate(ctx, s.live(versionFilter(name, ifVersion)),
+		bson.M{"$set": bson.M{"deleted_at": now, "updated_at": now}, "$inc": bson.M{"version": 1}},
+		opts).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		if ifVersion != nil {
+			return Field{}, s.missOrMismatch(ctx, name)
+		}
+		return Field{}, ErrNotFound
+	}
+	return before, err
+}
+
+func (s *mongoStore) Trash(ctx context.Context) ([]Field, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+	cursor, err := s.collection.Find(ctx, trashed(s.inScope(bson.M{})), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	ingredients := []Field{}
+	if err := cursor.All(ctx, &ingredients); err != nil {
+		return nil, err
+	}
+	return ingredients, nil
+}
+
+func (s *mongoStore) Restore(ctx context.Context, name string) (Field, error) {
+	var result Field
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.collection.FindOneAndUpdate(ctx, trashed(s.inScope(bson.M{"ingredient": name})), bson.M{
+		"$unset": bson.M{"deleted_at": ""},
+		"$set":   bson.M{"updated_at": time.Now()},
+		"$inc":   bson.M{"version": 1},
+	}, opts).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		return Field{}, ErrNotFound
+	}
+	return result, err
+}
+
+func (s *mongoStore) Purge(ctx context.Context, t time.Time) (int, error) {
+	result, err := s.collection.DeleteMany(ctx, s.inScope(bson.M{"deleted_at": bson.M{"$lte": t}}))
+	if err != nil {
+		return 0, err
+	}
+	return int(result.DeletedCount), nil
+}
+
+func (s *mongoStore) PriceHistory(ctx context.Context, name string) ([]PricePoint, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "recorded_at", Value: 1}})
+	cursor, err := s.history.Find(ctx, s.inScope(bson.M{"ingredient": name}), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	points := []PricePoint{}
+	if err := cursor.All(ctx, &points); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// recordPrice adds a price of f to the history of its pantry
+func (s *mongoStore) recordPrice(ctx context.Context, f Field, price Money, at time.Time) error {
+	_, err := s.history.InsertOne(ctx, PricePoint{
+		Ingredient: f.Ingredient, Owner: f.Owner, Household: f.Household, Price: price, RecordedAt: at,
+	})
+	return err
+}
+
+func (s *mongoStore) Aliases(ctx context.Context) ([]Alias, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := s.aliases.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	aliases := []Alias{}
+	if err := cursor.All(ctx, &aliases); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+func (s *mongoStore) Alias(ctx context.Context, key string) (Alias, error) {
+	var a Alias
+	err := s.aliases.FindOne(ctx, bson.M{"_id": key}).Decode(&a)
+	if err == mongo.ErrNoDocuments {
+		return Alias{}, ErrAliasNotFound
+	}
+	return a, err
+}
+
+func (s *mongoStore) PutAlias(ctx context.Context, a Alias) (bool, error) {
+	opts := options.Replace().SetUpsert(true)
+	result, err := s.aliases.ReplaceOne(ctx, bson.M{"_id": a.Key}, a, opts)
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+func (s *mongoStore) DeleteAlias(ctx context.Context, key string) error {
+	result, err := s.aliases.DeleteOne(ctx, bson.M{"_id": key})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrAliasNotFound
+	}
+	return nil
+}
+
+func (s *mongoStore) Households(ctx context.Context, userID string) ([]Household, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := s.households.Find(ctx, bson.M{"members.user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	households := []Household{}
+	if err := cursor.All(ctx, &households); err != nil {
+		return nil, err
+	}
+	return households, nil
+}
+
+func (s *mongoStore) Household(ctx context.Context, id string) (Household, error) {
+	var h Household
+	err := s.households.FindOne(ctx, bson.M{"_id": id}).Decode(&h)
+	if err == mongo.ErrNoDocuments {
+		return Household{}, ErrHouseholdNotFound
+	}
+	return h, err
+}
+
+func (s *mongoStore) PutHousehold(ctx context.Context, h Household) error {
+	_, err := s.households.ReplaceOne(ctx, bson.M{"_id": h.ID}, h, options.Replace().SetUpsert(true))
+	return err
+}
+
+// otherOwner matches households with an owner other than userID, which
+// still have one after userID is demoted or removed
+func otherOwner(userID string) bson.M {
+	return bson.M{"$elemMatch": bson.M{"role": roleOwner, "user_id": bson.M{"$ne": userID}}}
+}
+
+func (s *mongoStore) PutMember(ctx context.Context, id string, m Member) (Household, bool, error) {
+	filter := bson.M{"_id": id}
+	if m.Role != roleOwner {
+		filter["members"] = otherOwner(m.UserID)
+	}
+	// Change the member's role if they are in the household, otherwise add
+	// them, in a single update
+	member := bson.M{"user_id": m.UserID, "role": m.Role}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"members": bson.M{"$cond": bson.A{
+		bson.M{"$in": bson.A{m.UserID, "$members.user_id"}},
+		bson.M{"$map": bson.M{"input": "$members", "as": "m", "in": bson.M{"$cond": bson.A{
+			bson.M{"$eq": bson.A{"$$m.user_id", m.UserID}}, member, "$$m",
+		}}}},
+		bson.M{"$concatArrays": bson.A{"$members", bson.A{member}}},
+	}}}}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
+	var before Household
+	err := s.households.FindOneAndUpdate(ctx, filter, update, opts).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		// Either there is no such household, or the filter on owners failed
+		if _, err := s.Household(ctx, id); err != nil {
+			return Household{}, false, err
+		}
+		return Household{}, false, ErrLastOwner
+	}
+	if err != nil {
+		return Household{}, false, err
+	}
+	h, created := before.withMember(m)
+	return h, created, nil
+}
+
+func (s *mongoStore) RemoveMember(ctx context.Context, id, userID string) (Household, error) {
+	filter := bson.M{"_id": id, "members.user_id": userID, "members": otherOwner(userID)}
+	update := bson.M{"$pull": bson.M{"members": bson.M{"user_id": userID}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var h Household
+	err := s.households.FindOneAndUpdate(ctx, filter, update, opts).Decode(&h)
+	if err == mongo.ErrNoDocuments {
+		// Work out which part of the filter failed
+		current, err := s.Household(ctx, id)
+		if err != nil {
+			return Household{}, err
+		}
+		if current.role(userID) == "" {
+			return Household{}, ErrMemberNotFound
+		}
+		return Household{}, ErrLastOwner
+	}
+	return h, err
+}
+
+// DeleteHousehold checks the household is empty both before and after
+// deleting it. Ingredients can only be added while it exists, so one that
+// turns up in between is caught by the second check, and the household is
+// put back.
+func (s *mongoStore) DeleteHousehold(ctx context.Context, id string) error {
+	live := bson.M{"household": id, "deleted_at": nil}
+	if err := s.checkEmpty(ctx, live); err != nil {
+		return err
+	}
+	var h Household
+	if err := s.households.FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&h); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrHouseholdNotFound
+		}
+		return err
+	}
+	if err := s.checkEmpty(ctx, live); err != nil {
+		if _, putErr := s.households.InsertOne(ctx, h); putErr != nil {
+			return fmt.Errorf("putting back household %q: %w", id, putErr)
+		}
+		return err
+	}
+	_, err := s.collection.DeleteMany(ctx, bson.M{"household": id, "deleted_at": bson.M{"$ne": nil}})
+	return err
+}
+
+// checkEmpty returns ErrHouseholdNotEmpty when filter matches any
+// ingredient
+func (s *mongoStore) checkEmpty(ctx context.Context, filter bson.M) error {
+	n, err := s.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrHouseholdNotEmpty
+	}
+	return nil
+}
+
+func (s *mongoStore) RecordAudit(ctx context.Context, e AuditEntry) error {
+	_, err := s.audit.InsertOne(ctx, e)
+	return err
+}
+
+func (s *mongoStore) AuditLog(ctx context.Context, af AuditFilter) ([]AuditEntry, error) {
+	filter := s.inScope(bson.M{})
+	if af.Ingredient != "" {
+		filter["ingredient"] = af.Ingredient
+	}
+	if af.User != "" {
+		filter["user"] = af.User
+	}
+	at := bson.M{}
+	if !af.Since.IsZero() {
+		at["$gte"] = af.Since
+	}
+	if !af.Until.IsZero() {
+		at["$lt"] = af.Until
+	}
+	if len(at) > 0 {
+		filter["at"] = at
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: -1}})
+	if af.Limit > 0 {
+		opts.SetLimit(int64(af.Limit))
+	}
+	cursor, err := s.audit.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *mongoStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx, readpref.Primary())
+}
+
+func (s *mongoStore) Close(ctx context.Context) error {
+	return s.client.Disconnect(ctx)
+}
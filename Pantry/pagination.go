@@ -0,0 +1,229 @@
+package main
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// sortFields maps the sort names accepted by the list endpoint to the
+// stored field they order by
+var sortFields = map[string]string{
+	"name":       "ingredient",
+	"price":      "price",
+	"category":   "category",
+	"updated_at": "updated_at",
+}
+
+// listCursor is the position of the last item on a page. It holds the sort
+// value of that item plus its name, which breaks ties between equal values.
+type listCursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	Value      any    `json:"v,omitempty"`
+	Ingredient string `json:"i"`
+}
+
+// listPage is the response of the paginated list endpoint
+type listPage struct {
+	Items         []map[string]json.RawMessage `json:"items"`
+	Total         int                          `json:"total"`
+	NextPageToken string                       `json:"next_page_token,omitempty"`
+}
+
+// cursorAfter builds the cursor that resumes a listing after f
+func cursorAfter(f Field, sort string, descending bool) *listCursor {
+	c := &listCursor{Sort: sort, Descending: descending, Ingredient: f.Ingredient}
+	switch sort {
+	case "price":
+		c.Value = f.Price.Amount
+	case "category":
+		c.Value = f.Category
+	case "updated_at":
+		c.Value = f.UpdatedAt
+	}
+	return c
+}
+
+// encode turns the cursor into an opaque page token
+func (c *listCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a page token. The sort value is restored to the Go
+// type that the sort field is compared as.
+func decodeCursor(token string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+	var raw struct {
+		listCursor
+		Value json.RawMessage `json:"v"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+
+	c := raw.listCursor
+	switch c.Sort {
+	case "name":
+	case "price":
+		var v int64
+		err = json.Unmarshal(raw.Value, &v)
+		c.Value = v
+	case "category":
+		var v string
+		err = json.Unmarshal(raw.Value, &v)
+		c.Value = v
+	case "updated_at":
+		var v time.Time
+		err = json.Unmarshal(raw.Value, &v)
+		c.Value = v
+	default:
+		err = fmt.Errorf("unknown sort")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+	return &c, nil
+}
+
+// compareToCursor orders f against the cursor position in ascending sort
+// order, returning -1, 0 or 1
+func compareToCursor(f Field, c *listCursor) int {
+	var order int
+	switch v := c.Value.(type) {
+	case int64:
+		order = cmp.Compare(f.Price.Amount, v)
+	case string:
+		order = strings.Compare(f.Category, v)
+	case time.Time:
+		order = f.UpdatedAt.Compare(v)
+	}
+	if order == 0 {
+		order = strings.Compare(f.Ingredient, c.Ingredient)
+	}
+	return order
+}
+
+// lessBySort orders two ingredients by the sort field, then by name
+func lessBySort(a, b Field, sort string) bool {
+	return compareToCursor(a, cursorAfter(b, sort, false)) < 0
+}
+
+// fieldNames lists the JSON names of the Field struct, which are the names
+// accepted by fields=
+func fieldNames() map[string]bool {
+	names := make(map[string]bool)
+	t := reflect.TypeOf(Field{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// parseFields reads the fields= projection. The ingredient name is always
+// included, since it identifies the item.
+func parseFields(req *http.Request) (map[string]bool, error) {
+	v := req.URL.Query().Get("fields")
+	if v == "" {
+		return nil, nil
+	}
+	known := fieldNames()
+	fields := map[string]bool{"ingredient": true}
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		fields[name] = true
+	}
+	return fields, nil
+}
+
+// project renders f as a JSON object holding only the given fields, or
+// every field if fields is nil
+func project(f Field, fields map[string]bool) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	if fields != nil {
+		for name := range obj {
+			if !fields[name] {
+				delete(obj, name)
+			}
+		}
+	}
+	return obj, nil
+}
+
+// parseListQuery reads the paging, sorting and filter parameters of the
+// list endpoint:
+//
+//	category=a,b  sort=name|price|category|updated_at  order=asc|desc
+//	limit=N       page_token=TOKEN
+func parseListQuery(req *http.Request) (ListFilter, error) {
+	query := req.URL.Query()
+
+	categories, err := categoryFilter(req)
+	if err != nil {
+		return ListFilter{}, err
+	}
+	lf := ListFilter{Categories: categories, Sort: "name", Limit: defaultPageSize}
+
+	if v := query.Get("sort"); v != "" {
+		if _, ok := sortFields[v]; !ok {
+			return ListFilter{}, fmt.Errorf("sort must be name, price, category or updated_at: %q", v)
+		}
+		lf.Sort = v
+	}
+	switch v := query.Get("order"); v {
+	case "", "asc":
+	case "desc":
+		lf.Descending = true
+	default:
+		return ListFilter{}, fmt.Errorf("order must be asc or desc: %q", v)
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return ListFilter{}, fmt.Errorf("limit must be between 1 and %d: %q", maxPageSize, v)
+		}
+		lf.Limit = n
+	}
+	if v := query.Get("page_token"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil {
+			return ListFilter{}, err
+		}
+		if c.Sort != lf.Sort || c.Descending != lf.Descending {
+			return ListFilter{}, fmt.Errorf("page token was issued for a different sort order")
+		}
+		lf.After = c
+	}
+	return lf, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	f := Field{
+		Ingredient: "Flour",
+		Price:      Money{Amount: 249, Currency: "USD"},
+		Category:   "Baking",
+		UpdatedAt:  time.Date(2024, 3, 1, 12, 30, 0, 500, time.UTC),
+	}
+	for sort := range sortFields {
+		for _, descending := range []bool{false, true} {
+			c := cursorAfter(f, sort, descending)
+			got, err := decodeCursor(c.encode())
+			if err != nil {
+				t.Errorf("decoding %s cursor: %v", sort, err)
+				continue
+			}
+			if !reflect.DeepEqual(got, c) {
+				t.Errorf("%s cursor came back as %+v, want %+v", sort, got, c)
+			}
+			if order := compareToCursor(f, got); order != 0 {
+				t.Errorf("%s cursor compares to its own item as %d", sort, order)
+			}
+		}
+	}
+
+	for _, token := range []string{
+		"not base64!",
+		"bm90IGpzb24", // not json
+		(&listCursor{Sort: "colour", Ingredient: "Flour"}).encode(),                // unknown sort
+		(&listCursor{Sort: "price", Value: "cheap", Ingredient: "Flour"}).encode(), // wrong type
+	} {
+		if c, err := decodeCursor(token); err == nil {
+			t.Errorf("decodeCursor(%q) = %+v, want an error", token, c)
+		}
+	}
+}
+
+func TestListPagination(t *testing.T) {
+	store := newMemoryStore()
+	db := &database{store: store, auth: newAuthenticator(Config{}), timeouts: defaultConfig.Timeouts}
+	api := db.routes()
+
+	// Most items share their price, category and update time, so only the
+	// name tells them apart
+	early := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+	usd := func(cents int64) Money { return Money{Amount: cents, Currency: "USD"} }
+	items := []Field{
+		{Ingredient: "Apples", Price: usd(100), Category: "Produce", UpdatedAt: early},
+		{Ingredient: "Basil", Price: usd(100), Category: "Produce", UpdatedAt: early},
+		{Ingredient: "Carrots", Price: usd(100), Category: "Produce", UpdatedAt: early},
+		{Ingredient: "Dates", Price: usd(300), Category: "Produce", UpdatedAt: late},
+		{Ingredient: "Eggs", Price: usd(300), Category: "Dairy", UpdatedAt: late},
+		{Ingredient: "Flour", Price: usd(100), Category: "Baking", UpdatedAt: early},
+		{Ingredient: "Garlic", Price: usd(50), Category: "", UpdatedAt: late},
+	}
+	for _, f := range items {
+		f.CreatedAt = f.UpdatedAt
+		if _, err := store.Put(context.Background(), f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Ties are broken by name, in the same direction as the sort
+	ascending := map[string][]string{
+		"name":       {"Apples", "Basil", "Carrots", "Dates", "Eggs", "Flour", "Garlic"},
+		"price":      {"Garlic", "Apples", "Basil", "Carrots", "Flour", "Dates", "Eggs"},
+		"category":   {"Garlic", "Flour", "Eggs", "Apples", "Basil", "Carrots", "Dates"},
+		"updated_at": {"Apples", "Basil", "Carrots", "Flour", "Dates", "Eggs", "Garlic"},
+	}
+	for sort, asc := range ascending {
+		desc := make([]string, len(asc))
+		for i, name := range asc {
+			desc[len(asc)-1-i] = name
+		}
+		for order, want := range map[string][]string{"asc": asc, "desc": desc} {
+			for _, limit := range []string{"1", "2", "3", "7"} {
+				query := url.Values{"sort": {sort}, "order": {order}, "limit": {limit}}
+				var names []string
+				var page struct {
+					Items         []Field `json:"items"`
+					Total         int     `json:"total"`
+					NextPageToken string  `json:"next_page_token"`
+				}
+				for pages := 0; pages == 0 || page.NextPageToken != ""; pages++ {
+					if pages > len(items) {
+						t.Fatalf("%v: still paging after %d pages", query, pages)
+					}
+					if page.NextPageToken != "" {
+						query.Set("page_token", page.NextPageToken)
+					}
+					rec := call(api, "GET", "/ingredients?"+query.Encode(), "")
+					if rec.Code != http.StatusOK {
+						t.Fatalf("%v: got %d %s", query, rec.Code, rec.Body)
+					}
+					page.NextPageToken = ""
+					if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+						t.Fatal(err)
+					}
+					if page.Total != len(items) {
+						t.Errorf("%v: total = %d, want %d", query, page.Total, len(items))
+					}
+					for _, f := range page.Items {
+						names = append(names, f.Ingredient)
+					}
+				}
+
+				if !reflect.DeepEqual(names, want) {
+					t.Errorf("sort=%s order=%s limit=%s paged through\n\t%v, want\n\t%v", sort, order, limit, names, want)
+				}
+			}
+		}
+	}
+
+	// A token only resumes the listing it came from
+	rec := call(api, "GET", "/ingredients?sort=price&limit=1", "")
+	var page struct {
+		NextPageToken string `json:"next_page_token"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &page)
+	for _, query := range []string{"sort=category", "sort=price&order=desc"} {
+		rec := call(api, "GET", "/ingredients?"+query+"&page_token="+page.NextPageToken, "")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s with a price token: got %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"Service"
+)
+
+const (
+	defaultSearchLimit       = 20
+	defaultAutocompleteLimit = 10
+)
+
+// Search match kinds, best first
+const (
+	matchExact    = "exact"
+	matchPrefix   = "prefix"
+	matchWord     = "word"
+	matchContains = "contains"
+	matchFuzzy    = "fuzzy"
+)
+
+// searchResult is one ranked search hit
+type searchResult struct {
+	Field
+	Score int    `json:"score"`
+	Match string `json:"match"`
+}
+
+// maxTypos is how many edits a query of length n may be away from a name
+// and still count as a fuzzy match
+func maxTypos(n int) int {
+	switch {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	}
+	return 2
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// insertions, deletions, substitutions and swaps of adjacent letters each
+// cost one edit
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+// scoreName ranks how well a normalized name matches a normalized query.
+// It returns a score of 0 when the name does not match at all.
+func scoreName(name, query string) (int, string) {
+	switch {
+	case name == query:
+		return 100, matchExact
+	case strings.HasPrefix(name, query):
+		return 90, matchPrefix
+	}
+	for _, w := range strings.Fields(name) {
+		if strings.HasPrefix(w, query) {
+			return 80, matchWord
+		}
+	}
+	if strings.Contains(name, query) {
+		return 70, matchContains
+	}
+
+	// Typo tolerance: compare against the whole name and against each word
+	best := editDistance(name, query)
+	for _, w := range strings.Fields(name) {
+		best = min(best, editDistance(w, query))
+	}
+	if limit := maxTypos(len([]rune(query))); best <= limit && best > 0 {
+		return 60 - 10*best, matchFuzzy
+	}
+	return 0, ""
+}
+
+// searchLimit reads the limit query parameter
+func searchLimit(req *http.Request, def int) (int, bool) {
+	v := req.URL.Query().Get("limit")
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxPageSize {
+		return 0, false
+	}
+	return n, true
+}
+
+// search returns the ingredients matching q, best match first. Matching
+// ignores case, plurals and small typos.
+func (db *database) search(w http.ResponseWriter, req *http.Request) {
+	query := service.NormalizeName(req.URL.Query().Get("q"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "q is required")
+		return
+	}
+	limit, ok := searchLimit(req, defaultSearchLimit)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "limit must be between 1 and %d", maxPageSize)
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.List)
+	defer cancel()
+
+	results := []searchResult{}
+	err := pantry(req).Each(ctx, ListFilter{}, func(f Field) error {
+		if score, match := scoreName(service.NormalizeName(f.Ingredient), query); score > 0 {
+			results = append(results, searchResult{Field: f, Score: score, Match: match})
+		}
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// autocomplete returns the names of ingredients that start with q, or have
+// a word that does, for the frontend's ingredient input
+func (db *database) autocomplete(w http.ResponseWriter, req *http.Request) {
+	prefix := strings.ToLower(strings.Join(strings.Fields(req.URL.Query().Get("q")), " "))
+	limit, ok := searchLimit(req, defaultAutocompleteLimit)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "limit must be between 1 and %d", maxPageSize)
+		return
+	}
+	if prefix == "" {
+		writeJSON(w, http.StatusOK, []string{})
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.List)
+	defer cancel()
+
+	type suggestion struct {
+		name  string
+		score int
+	}
+	var suggestions []suggestion
+	err := pantry(req).Each(ctx, ListFilter{}, func(f Field) error {
+		name := strings.ToLower(f.Ingredient)
+		switch {
+		case strings.HasPrefix(name, prefix):
+			suggestions = append(suggestions, suggestion{f.Ingredient, 2})
+		case strings.Contains(name, " "+prefix):
+			suggestions = append(suggestions, suggestion{f.Ingredient, 1})
+		}
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	// Each returns names in order, so a stable sort keeps them alphabetical
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].score > suggestions[j].score
+	})
+	names := []string{}
+	for _, s := range suggestions {
+		if len(names) == limit {
+			break
+		}
+		names = append(names, s.name)
+	}
+
+	writeJSON(w, http.StatusOK, names)
+}
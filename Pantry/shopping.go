@@ -0,0 +1,98 @@
+package main
+
+import "net/http"
+
+// shoppingItem is one line of the shopping list
+type shoppingItem struct {
+	Ingredient       string  `json:"ingredient"`
+	Unit             string  `json:"unit"`
+	Quantity         float64 `json:"quantity"`
+	ReorderThreshold float64 `json:"reorder_threshold"`
+	BuyQuantity      float64 `json:"buy_quantity"`
+	Price            Money   `json:"price"`
+	EstimatedCost    Money   `json:"estimated_cost"`
+}
+
+// shoppingGroup is the part of the shopping list for one category
+type shoppingGroup struct {
+	Category string         `json:"category"`
+	Count    int            `json:"count"`
+	Subtotal Money          `json:"subtotal"`
+	Items    []shoppingItem `json:"items"`
+}
+
+// shoppingList is the response of the shopping list endpoint
+type shoppingList struct {
+	Count  int             `json:"count"`
+	Total  Money           `json:"total"`
+	Groups []shoppingGroup `json:"groups"`
+}
+
+// needsRestock reports whether f has dropped below its reorder threshold
+func (f Field) needsRestock() bool {
+	return f.ReorderThreshold > 0 && f.Quantity < f.ReorderThreshold
+}
+
+// buyQuantity is how much of f to buy: the preferred purchase quantity, or
+// else enough to get back up to the threshold
+func (f Field) buyQuantity() float64 {
+	if f.PurchaseQuantity > 0 {
+		return f.PurchaseQuantity
+	}
+	return f.ReorderThreshold - f.Quantity
+}
+
+// shoppingList returns every item below its reorder threshold, grouped by
+// category in the same order as the category summary
+func (db *database) shoppingList(w http.ResponseWriter, req *http.Request) {
+	filter, err := categoryFilter(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_parameter", "%v", err)
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.List)
+	defer cancel()
+
+	list := shoppingList{Groups: []shoppingGroup{}}
+	buckets := make(map[string]*shoppingGroup)
+	err = pantry(req).Each(ctx, ListFilter{Categories: filter}, func(f Field) error {
+		if !f.needsRestock() {
+			return nil
+		}
+		item := shoppingItem{
+			Ingredient:       f.Ingredient,
+			Unit:             f.Unit,
+			Quantity:         f.Quantity,
+			ReorderThreshold: f.ReorderThreshold,
+			BuyQuantity:      f.buyQuantity(),
+			Price:            f.Price,
+		}
+		item.EstimatedCost = f.Price.Mul(item.BuyQuantity)
+
+		g, ok := buckets[f.Category]
+		if !ok {
+			g = &shoppingGroup{Category: f.Category, Items: []shoppingItem{}}
+			buckets[f.Category] = g
+		}
+		g.Items = append(g.Items, item)
+		g.Count++
+		list.Count++
+		var err error
+		if g.Subtotal, err = g.Subtotal.Add(item.EstimatedCost); err != nil {
+			return err
+		}
+		list.Total, err = list.Total.Add(item.EstimatedCost)
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	for _, c := range categoryOrder(buckets) {
+		list.Groups = append(list.Groups, *buckets[c])
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrInsufficientStock is returned when consuming more than is in stock
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrUnitMismatch is returned when adjusting stock in another unit
+	// than the ingredient is stocked in
+	ErrUnitMismatch = errors.New("unit mismatch")
+)
+
+// units lists the accepted units of measure. An empty unit means the
+// quantity is a plain count.
+var units = map[string]bool{
+	"":        true,
+	"each":    true,
+	"g":       true,
+	"kg":      true,
+	"oz":      true,
+	"lb":      true,
+	"ml":      true,
+	"l":       true,
+	"tsp":     true,
+	"tbsp":    true,
+	"cup":     true,
+	"can":     true,
+	"bottle":  true,
+	"package": true,
+	"bunch":   true,
+	"clove":   true,
+	"slice":   true,
+}
+
+// stockRequest is the JSON body accepted by the add and consume endpoints
+type stockRequest struct {
+	Amount float64 `json:"amount"`
+	Unit   *string `json:"unit"`
+}
+
+// validateQuantity rejects negative, NaN and infinite quantities
+func validateQuantity(q float64) error {
+	if math.IsNaN(q) || math.IsInf(q, 0) {
+		return fmt.Errorf("quantity must be a finite number")
+	}
+	if q < 0 {
+		return fmt.Errorf("quantity must not be negative: %g", q)
+	}
+	return nil
+}
+
+// normalizeUnit lower-cases a unit and checks it against the known units
+func normalizeUnit(u string) (string, error) {
+	u = strings.ToLower(strings.TrimSpace(u))
+	if !units[u] {
+		known := make([]string, 0, len(units))
+		for k := range units {
+			if k != "" {
+				known = append(known, k)
+			}
+		}
+		sort.Strings(known)
+		return "", fmt.Errorf("unknown unit %q (want one of %s)", u, strings.Join(known, ", "))
+	}
+	return u, nil
+}
+
+// unitMismatch reports that f is stocked in another unit than unit
+func unitMismatch(f Field, unit string) error {
+	return fmt.Errorf("%w: %s is stocked in %q, not %q", ErrUnitMismatch, f.Ingredient, f.Unit, unit)
+}
+
+func (db *database) addStock(w http.ResponseWriter, req *http.Request) {
+	db.adjustStock(w, req, 1)
+}
+
+func (db *database) consumeStock(w http.ResponseWriter, req *http.Request) {
+	db.adjustStock(w, req, -1)
+}
+
+// adjustStock adds (sign 1) or removes (sign -1) an amount from an
+// ingredient's quantity
+func (db *database) adjustStock(w http.ResponseWriter, req *http.Request, sign float64) {
+	var body stockRequest
+	if !decodeBody(w, req, &body) {
+		return
+	}
+	if err := validateQuantity(body.Amount); err != nil || body.Amount == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_field", "amount must be a positive number")
+		return
+	}
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
+	name, ok := db.pathIngredient(ctx, w, req)
+	if !ok {
+		return
+	}
+
+	// The amount has to be in the unit the ingredient is stocked in, which
+	// the store checks along with If-Match
+	var unit *string
+	if body.Unit != nil {
+		u, err := normalizeUnit(*body.Unit)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_field", "%v", err)
+			return
+		}
+		unit = &u
+	}
+
+	ch, err := pantry(req).AdjustQuantity(ctx, name, sign*body.Amount, unit, ifMatch(req))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInsufficientStock):
+			writeError(w, http.StatusConflict, "insufficient_stock", "not enough %s in stock to consume %g", name, body.Amount)
+		case errors.Is(err, ErrUnitMismatch):
+			writeError(w, http.StatusConflict, "unit_mismatch", "%v", err)
+		default:
+			writeStoreError(w, err, name)
+		}
+		return
+	}
+
+	writeField(w, http.StatusOK, ch.After)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestAdjustStock(t *testing.T) {
+	api := newTestAPI(t)
+	rec := call(api, "POST", "/ingredients", `{"ingredient": "Flour", "price": "2.50", "quantity": 2, "unit": "kg"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: got %d %s", rec.Code, rec.Body)
+	}
+	created := decodeField(t, rec)
+	version := strconv.FormatInt(created.Version, 10)
+	stale := strconv.FormatInt(created.Version-1, 10)
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		ifMatch string
+		status  int
+		code    string
+	}{
+		{"other unit", "consume", `{"amount": 1, "unit": "g"}`, "", http.StatusConflict, "unit_mismatch"},
+		{"stale version", "consume", `{"amount": 1, "unit": "kg"}`, stale, http.StatusPreconditionFailed, "precondition_failed"},
+		{"too much", "consume", `{"amount": 5}`, "", http.StatusConflict, "insufficient_stock"},
+		{"matching unit and version", "consume", `{"amount": 1, "unit": "KG"}`, version, http.StatusOK, ""},
+		{"add", "add", `{"amount": 3}`, "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		var headers []string
+		if tt.ifMatch != "" {
+			headers = []string{"If-Match", `"` + tt.ifMatch + `"`}
+		}
+		rec := call(api, "POST", "/ingredients/Flour/"+tt.path, tt.body, headers...)
+		if rec.Code != tt.status {
+			t.Errorf("%s: got %d %s, want %d", tt.name, rec.Code, rec.Body, tt.status)
+			continue
+		}
+		if tt.code != "" {
+			if code := errorCode(t, rec); code != tt.code {
+				t.Errorf("%s: error code = %q, want %q", tt.name, code, tt.code)
+			}
+		}
+	}
+
+	rec = call(api, "GET", "/ingredients/Flour", "")
+	if f := decodeField(t, rec); f.Quantity != 4 {
+		t.Errorf("quantity = %g, want 4", f.Quantity)
+	}
+}
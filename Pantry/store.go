@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when an ingredient does not exist
+	ErrNotFound = errors.New("ingredient not found")
+	// ErrExists is returned when creating an ingredient that already exists
+	ErrExists = errors.New("ingredient already exists")
+	// ErrVersionMismatch is returned when a conditional write finds the
+	// ingredient at a different version than expected
+	ErrVersionMismatch = errors.New("ingredient has been modified")
+)
+
+// Scope selects one pantry: a household's shared pantry when Household is
+// set, otherwise the personal pantry of Owner. The zero Scope is the pantry
+// used when authentication is off, which also holds the items saved before
+// pantries were scoped.
+type Scope struct {
+	Owner     string
+	Household string
+}
+
+// personalScope is a user's own pantry
+func personalScope(userID string) Scope {
+	return Scope{Owner: userID}
+}
+
+// householdScope is the pantry shared by a household's members
+func householdScope(id string) Scope {
+	return Scope{Household: id}
+}
+
+// PantryStore is the storage used by the pantry handlers. Implementations
+// must be safe for concurrent use.
+//
+// Deleted ingredients go to the trash, where only Trash, Restore and Purge
+// see them. Creating an ingredient with the name of one in the trash, in
+// any spelling, discards the trashed one.
+//
+// The ingredient methods of a store returned by Scoped only see and write
+// that pantry. The store the service opens is unscoped and sees every
+// pantry; it is used by the command-line tools and the expiry sweep.
+type PantryStore interface {
+	// Scoped returns a view of the store limited to one pantry
+	Scoped(sc Scope) PantryStore
+
+	// List returns the ingredients that match filter, in the order and
+	// page it asks for
+	List(ctx context.Context, filter ListFilter) ([]Field, error)
+	// Count returns how many ingredients match filter, ignoring paging
+	Count(ctx context.Context, filter ListFilter) (int, error)
+	// Each calls fn for every ingredient that matches filter, without
+	// loading the whole pantry into memory. It stops at the first error.
+	Each(ctx context.Context, filter ListFilter, fn func(Field) error) error
+	// Get returns the ingredient with the given name or ErrNotFound
+	Get(ctx context.Context, name string) (Field, error)
+	// Match returns the ingredient whose name normalizes to key, or
+	// ErrNotFound
+	Match(ctx context.Context, key string) (Field, error)
+	// Create inserts a new ingredient, or returns ErrExists if the pantry
+	// already holds one whose name normalizes the same. The initial price
+	// is recorded in the price history.
+	Create(ctx context.Context, f Field) (Field, error)
+	// Update applies u to an existing ingredient. A changed price is
+	// recorded in the price history. If u.IfVersion is set and the
+	// ingredient is at another version, it returns ErrVersionMismatch and
+	// changes nothing.
+	Update(ctx context.Context, name string, u FieldUpdate) (Change, error)
+	// Upsert creates f if no ingredient whose name normalizes the same
+	// exists, or otherwise applies u to the stored one, as a single atomic
+	// step
+	Upsert(ctx context.Context, f Field, u FieldUpdate) (Change, error)
+	// Put stores f as-is, replacing any ingredient whose name normalizes
+	// the same, and reports whether it was new. It is meant for restoring
+	// backups, so timestamps are kept and no price history is recorded.
+	Put(ctx context.Context, f Field) (bool, error)
+	// AdjustQuantity adds delta to an ingredient's quantity. It returns
+	// ErrInsufficientStock instead of letting the quantity go negative. If
+	// unit is set and the ingredient is stocked in another unit, it returns
+	// ErrUnitMismatch, and if ifVersion is set and the ingredient is at
+	// another version, ErrVersionMismatch; either way nothing changes.
+	AdjustQuantity(ctx context.Context, name string, delta float64, unit *string, ifVersion *int64) (Change, error)
+	// Expiring returns the items with an expiry date at or before t,
+	// soonest first
+	Expiring(ctx context.Context, t time.Time) ([]Field, error)
+	// MarkExpired flags every item whose expiry date has passed at now and
+	// returns how many were changed
+	MarkExpired(ctx context.Context, now time.Time) (int, error)
+	// Delete moves an ingredient to the trash, or returns ErrNotFound, and
+	// returns the ingredient as it was before. If ifVersion is not nil the
+	// ingredient is only deleted at that version; otherwise
+	// ErrVersionMismatch is returned.
+	Delete(ctx context.Context, name string, ifVersion *int64) (Field, error)
+	// Trash returns the deleted ingredients, most recently deleted first
+	Trash(ctx context.Context) ([]Field, error)
+	// Restore takes an ingredient out of the trash, or returns ErrNotFound
+	Restore(ctx context.Context, name string) (Field, error)
+	// Purge removes for good the ingredients deleted at or before t and
+	// returns how many there were
+	Purge(ctx context.Context, t time.Time) (int, error)
+	// PriceHistory returns the recorded prices of an ingredient, oldest
+	// first. History is kept after the ingredient is deleted.
+	PriceHistory(ctx context.Context, name string) ([]PricePoint, error)
+	// Aliases returns the alias registry, sorted by alias
+	Aliases(ctx context.Context) ([]Alias, error)
+	// Alias returns the alias with the given normalized key or
+	// ErrAliasNotFound
+	Alias(ctx context.Context, key string) (Alias, error)
+	// PutAlias adds or replaces an alias and reports whether it was new
+	PutAlias(ctx context.Context, a Alias) (bool, error)
+	// DeleteAlias removes an alias, or returns ErrAliasNotFound
+	DeleteAlias(ctx context.Context, key string) error
+	// Households returns the households userID is a member of
+	Households(ctx context.Context, userID string) ([]Household, error)
+	// Household returns a household or ErrHouseholdNotFound
+	Household(ctx context.Context, id string) (Household, error)
+	// PutHousehold creates or replaces a household
+	PutHousehold(ctx context.Context, h Household) error
+	// PutMember adds a member to a household, or changes their role, in
+	// one atomic step and reports whether they were new. It returns
+	// ErrLastOwner, and changes nothing, if that would leave the household
+	// without an owner.
+	PutMember(ctx context.Context, id string, m Member) (Household, bool, error)
+	// RemoveMember takes a member out of a household in one atomic step. It
+	// returns ErrMemberNotFound, or ErrLastOwner if they are the only owner.
+	RemoveMember(ctx context.Context, id, userID string) (Household, error)
+	// DeleteHousehold removes a household and the ingredients in its
+	// trash. It returns ErrHouseholdNotFound, or ErrHouseholdNotEmpty, and
+	// changes nothing, while the household has ingredients that aren't in
+	// the trash.
+	DeleteHousehold(ctx context.Context, id string) error
+	// RecordAudit appends an entry to the audit log. Entries are never
+	// changed or removed.
+	RecordAudit(ctx context.Context, e AuditEntry) error
+	// AuditLog returns the audit entries that match filter, newest first
+	AuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+	// Ping checks that the store can be reached
+	Ping(ctx context.Context) error
+	// Close releases any resources held by the store
+	Close(ctx context.Context) error
+}
+
+// Change is the outcome of a write: the ingredient as it was before and
+// after, read in the same atomic step as the write itself. Before is the
+// zero Field when the write created the ingredient.
+type Change struct {
+	Before  Field
+	After   Field
+	Created bool
+}
+
+// ListFilter narrows down and orders the ingredients returned by List. The
+// zero value matches everything, sorted by name.
+type ListFilter struct {
+	// Categories keeps items in any of the given categories
+	Categories []string
+	// IncludeTrash also returns the items in the trash, for backups
+	IncludeTrash bool
+
+	// Sort is one of the keys of sortFields; empty means by name
+	Sort       string
+	Descending bool
+	// Limit caps the number of items returned; 0 means no limit
+	Limit int
+	// After resumes the listing after the item the cursor points at
+	After *listCursor
+}
+
+// matches reports whether f passes the category and trash filters. Paging
+// is not taken into account.
+func (lf ListFilter) matches(f Field) bool {
+	if f.DeletedAt != nil && !lf.IncludeTrash {
+		return false
+	}
+	if len(lf.Categories) == 0 {
+		return true
+	}
+	for _, c := range lf.Categories {
+		if f.Category == c {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldUpdate lists the fields to change on an ingredient. Nil fields are
+// left untouched; a zero date clears the stored date.
+type FieldUpdate struct {
+	// IfVersion makes the update conditional on the stored version
+	IfVersion *int64
+
+	Price       *Money
+	Category    *string
+	Quantity    *float64
+	Unit        *string
+	PurchasedAt *time.Time
+	ExpiresAt   *time.Time
+
+	ReorderThreshold *float64
+	PurchaseQuantity *float64
+}
+
+// apply sets the fields of u on f and moves it to the next version
+func (u FieldUpdate) apply(f *Field, now time.Time) {
+	if u.Price != nil {
+		f.Price = *u.Price
+	}
+	if u.Category != nil {
+		f.Category = *u.Category
+	}
+	if u.Quantity != nil {
+		f.Quantity = *u.Quantity
+	}
+	if u.Unit != nil {
+		f.Unit = *u.Unit
+	}
+	if u.PurchasedAt != nil {
+		f.PurchasedAt = optionalDate(*u.PurchasedAt)
+	}
+	if u.ExpiresAt != nil {
+		f.ExpiresAt = optionalDate(*u.ExpiresAt)
+		f.Expired = isExpired(f.ExpiresAt, now)
+	}
+	if u.ReorderThreshold != nil {
+		f.ReorderThreshold = *u.ReorderThreshold
+	}
+	if u.PurchaseQuantity != nil {
+		f.PurchaseQuantity = *u.PurchaseQuantity
+	}
+	f.UpdatedAt = now
+	f.Version++
+}
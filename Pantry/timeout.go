@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"Service"
+)
+
+// Timeouts bounds how long each kind of request may take. The time runs
+// from when the handler starts, and a request whose client goes away is
+// cancelled straight away.
+type Timeouts struct {
+	// Read covers single items, aliases and households
+	Read service.Duration `json:"read"`
+	// Write covers every change to items, aliases and households
+	Write service.Duration `json:"write"`
+	// List covers listings, search, summaries and the audit log
+	List service.Duration `json:"list"`
+	// Bulk covers import and export
+	Bulk service.Duration `json:"bulk"`
+}
+
+// withTimeout derives the context for one store operation from the
+// request's
+func (db *database) withTimeout(req *http.Request, d service.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(req.Context(), time.Duration(d))
+}
+
+// isTimeout reports whether err comes from running out of time, either on
+// the context or on a network call
+func isTimeout(err error) bool {
+	var netErr interface{ Timeout() bool }
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout()
+}
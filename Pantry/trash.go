@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// listTrash returns the deleted items of the pantry, most recently deleted
+// first
+func (db *database) listTrash(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := db.withTimeout(req, db.timeouts.List)
+	defer cancel()
+
+	ingredients, err := pantry(req).Trash(ctx)
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ingredients)
+}
+
+// restoreIngredient takes an item out of the trash. Trashed items are
+// looked up by their exact name, since aliases only resolve to live ones.
+func (db *database) restoreIngredient(w http.ResponseWriter, req *http.Request) {
+	name := cleanName(req.PathValue("name"))
+
+	ctx, cancel := db.withTimeout(req, db.timeouts.Write)
+	defer cancel()
+
+	f, err := pantry(req).Restore(ctx, name)
+	if err != nil {
+		writeStoreError(w, err, name)
+		return
+	}
+
+	writeField(w, http.StatusOK, f)
+}
+
+// purgeTrash removes items that have been in the trash for longer than
+// retention, every interval until ctx is cancelled
+func purgeTrash(ctx context.Context, store PantryStore, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		n, err := store.Purge(purgeCtx, time.Now().Add(-retention))
+		cancel()
+		if err != nil {
+			log.Printf("trash purge: %v", err)
+		} else if n > 0 {
+			log.Printf("trash purge: removed %d item(s)", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
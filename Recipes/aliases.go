@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"Service"
+)
+
+// aliasRefresh is how long the alias registry fetched from the pantry is
+// used before it is fetched again
+const aliasRefresh = time.Minute
+
+// aliasTable maps normalized aliases to normalized canonical names
+type aliasTable struct {
+	canonical map[string]string
+	// keys are the aliases, longest first, in the order canonicalize
+	// replaces them
+	keys []string
+}
+
+func newAliasTable(canonical map[string]string) aliasTable {
+	keys := make([]string, 0, len(canonical))
+	for alias := range canonical {
+		keys = append(keys, alias)
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	return aliasTable{canonical: canonical, keys: keys}
+}
+
+// pantryAliases caches the pantry's alias registry
+var pantryAliases aliasCache
+
+type aliasCache struct {
+	mu         sync.Mutex
+	table      aliasTable
+	fetched    time.Time
+	refreshing bool
+}
+
+// get returns the alias table, fetching it from the pantry service when it
+// is stale. The fetch happens outside the lock, and meanwhile other
+// requests get the last table, as they do when the pantry can't be
+// reached.
+func (c *aliasCache) get() aliasTable {
+	c.mu.Lock()
+	table := c.table
+	if c.refreshing || time.Since(c.fetched) < aliasRefresh {
+		c.mu.Unlock()
+		return table
+	}
+	c.refreshing = true
+	c.mu.Unlock()
+
+	fetched, err := fetchAliases()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refreshing = false
+	c.fetched = time.Now()
+	if err != nil {
+		log.Printf("fetching ingredient aliases: %v", err)
+		return c.table
+	}
+	c.table = fetched
+	return fetched
+}
+
+// fetchAliases reads the alias registry from the pantry service
+func fetchAliases() (aliasTable, error) {
+	req, err := http.NewRequest("GET", config.PantryURL+"/aliases", nil)
+	if err != nil {
+		return aliasTable{}, err
+	}
+	if config.PantryToken != "" {
+		req.Header.Set("Authorization", "Bearer "+config.PantryToken)
+	}
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return aliasTable{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return aliasTable{}, fmt.Errorf("pantry returned %s", resp.Status)
+	}
+
+	var aliases []struct {
+		Alias     string `json:"alias"`
+		Canonical string `json:"canonical"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&aliases); err != nil {
+		return aliasTable{}, err
+	}
+	canonical := make(map[string]string, len(aliases))
+	for _, a := range aliases {
+		canonical[service.NormalizeName(a.Alias)] = service.NormalizeName(a.Canonical)
+	}
+	return newAliasTable(canonical), nil
+}
+
+// canonicalize normalizes text and replaces every alias in it with its
+// canonical name. Longer aliases are replaced first, so "green onion" wins
+// over "onion".
+func (t aliasTable) canonicalize(text string) string {
+	padded := " " + service.NormalizeName(text) + " "
+	for _, alias := range t.keys {
+		padded = strings.ReplaceAll(padded, " "+alias+" ", " "+t.canonical[alias]+" ")
+	}
+	return strings.TrimSpace(padded)
+}
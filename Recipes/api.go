@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxBodyBytes caps the size of a recipe sent by a client
+const maxBodyBytes = 1 << 20
+
+// storeTimeout is how long a request may spend on the store
+const storeTimeout = 5 * time.Second
+
+// recipeService serves the recipes in a store
+type recipeService struct {
+	store RecipeStore
+}
+
+// apiError is the body of every error response of the recipe management
+// endpoints
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, format string, args ...any) {
+	writeJSON(w, status, struct {
+		Error apiError `json:"error"`
+	}{apiError{Code: code, Message: fmt.Sprintf(format, args...)}})
+}
+
+// writeStoreError maps a store error onto a response
+func writeStoreError(w http.ResponseWriter, err error, id string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, "not_found", "no such recipe: %q", id)
+	case errors.Is(err, ErrExists):
+		writeError(w, http.StatusConflict, "already_exists", "recipe already exists: %s", id)
+	case errors.Is(err, context.DeadlineExceeded):
+		writeError(w, http.StatusGatewayTimeout, "timeout", "the recipe store did not answer in time")
+	case errors.Is(err, context.Canceled):
+		// The client has gone away, so nobody reads the response
+	default:
+		writeError(w, http.StatusInternalServerError, "internal", "%v", err)
+	}
+}
+
+// decodeRecipe reads and validates the recipe in the request body. The ID
+// and timestamps are the server's to set, so any sent are ignored.
+func decodeRecipe(w http.ResponseWriter, req *http.Request) (Recipe, bool) {
+	var r Recipe
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		if errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "invalid_body", "request body is empty")
+			return Recipe{}, false
+		}
+		writeError(w, http.StatusBadRequest, "invalid_body", "invalid JSON body: %v", err)
+		return Recipe{}, false
+	}
+	if dec.More() {
+		writeError(w, http.StatusBadRequest, "invalid_body", "request body must contain a single JSON object")
+		return Recipe{}, false
+	}
+	if err := r.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_field", "%v", err)
+		return Recipe{}, false
+	}
+	r.ID, r.CreatedAt, r.UpdatedAt, r.Fixture = "", time.Time{}, time.Time{}, nil
+	return r, true
+}
+
+func (rs *recipeService) listRecipes(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), storeTimeout)
+	defer cancel()
+
+	recipes, err := rs.store.List(ctx)
+	if err != nil {
+		writeStoreError(w, err, "")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, recipes)
+}
+
+// createRecipe stores a new recipe under a generated ID
+func (rs *recipeService) createRecipe(w http.ResponseWriter, req *http.Request) {
+	r, ok := decodeRecipe(w, req)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), storeTimeout)
+	defer cancel()
+
+	r.ID = newRecipeID()
+	created, err := rs.store.Create(ctx, r)
+	if err != nil {
+		writeStoreError(w, err, r.ID)
+		return
+	}
+
+	w.Header().Set("Location", "/recipes/"+url.PathEscape(created.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (rs *recipeService) getRecipe(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+
+	ctx, cancel := context.WithTimeout(req.Context(), storeTimeout)
+	defer cancel()
+
+	r, err := rs.store.Get(ctx, id)
+	if err != nil {
+		writeStoreError(w, err, id)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, r)
+}
+
+// updateRecipe replaces a recipe with the one in the request body
+func (rs *recipeService) updateRecipe(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	r, ok := decodeRecipe(w, req)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), storeTimeout)
+	defer cancel()
+
+	r.ID = id
+	updated, err := rs.store.Update(ctx, r)
+	if err != nil {
+		writeStoreError(w, err, id)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// convertSpoonacular converts a recipe from the Spoonacular API into the
+// service's format, with its ingredients and steps parsed, without storing
+// it. The web frontend uses it for recipes it fetches from Spoonacular.
+func (rs *recipeService) convertSpoonacular(w http.ResponseWriter, req *http.Request) {
+	raw, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxBodyBytes))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "reading request body: %v", err)
+		return
+	}
+	r, err := fromSpoonacular(raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "invalid JSON body: %v", err)
+		return
+	}
+	if err := r.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_field", "%v", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, r)
+}
+
+func (rs *recipeService) deleteRecipe(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+
+	ctx, cancel := context.WithTimeout(req.Context(), storeTimeout)
+	defer cancel()
+
+	if err := rs.store.Delete(ctx, id); err != nil {
+		writeStoreError(w, err, id)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rs *recipeService) detailHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse the recipe ID from the query parameters
+	id := r.URL.Query().Get("id")
+
+	// Fetch the recipe with the corresponding ID from the store
+	ctx, cancel := context.WithTimeout(r.Context(), storeTimeout)
+	defer cancel()
+
+	recipe, err := rs.store.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "Recipe not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to load recipe", http.StatusInternalServerError)
+		return
+	}
+
+	// Recipes saved before steps were parsed get them on the fly
+	if recipe.Steps == nil {
+		recipe.Steps = parseSteps(recipe.Instructions)
+	}
+
+	// Marshal the recipe into JSON format
+	recipeJSON, err := json.Marshal(recipe)
+	if err != nil {
+		http.Error(w, "Failed to marshal recipe JSON", http.StatusInternalServerError)
+		return
+	}
+
+	// Set the Content-Type header to application/json
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write the JSON response
+	w.Write(recipeJSON)
+}
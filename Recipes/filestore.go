@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fileStore keeps the recipes in memory and writes them all to a JSON file
+// after every change. It suits a single instance of the service; two
+// processes sharing the file would overwrite each other's changes.
+type fileStore struct {
+	mu      sync.RWMutex
+	path    string
+	recipes map[string]Recipe
+}
+
+// newFileStore loads the recipes in path. A missing file is an empty store.
+func newFileStore(path string) (*fileStore, error) {
+	s := &fileStore{path: path, recipes: make(map[string]Recipe)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var recipes []Recipe
+	if err := json.Unmarshal(data, &recipes); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	for _, r := range recipes {
+		s.recipes[r.ID] = r
+	}
+	return s, nil
+}
+
+// sorted returns the recipes ordered by ID; s.mu must be held
+func (s *fileStore) sorted() []Recipe {
+	recipes := make([]Recipe, 0, len(s.recipes))
+	for _, r := range s.recipes {
+		recipes = append(recipes, r)
+	}
+	sort.Slice(recipes, func(i, j int) bool { return recipes[i].ID < recipes[j].ID })
+	return recipes
+}
+
+// save writes the recipes to a temporary file and renames it over the
+// store file, so a crash never leaves it half written; s.mu must be held
+func (s *fileStore) save() error {
+	data, err := json.MarshalIndent(s.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *fileStore) List(ctx context.Context) ([]Recipe, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sorted(), nil
+}
+
+func (s *fileStore) Get(ctx context.Context, id string) (Recipe, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.recipes[id]
+	if !ok {
+		return Recipe{}, ErrNotFound
+	}
+	return r, nil
+}
+
+func (s *fileStore) Create(ctx context.Context, r Recipe) (Recipe, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.recipes[r.ID]; ok {
+		return Recipe{}, ErrExists
+	}
+	now := time.Now().UTC()
+	r.CreatedAt, r.UpdatedAt = now, now
+	s.recipes[r.ID] = r
+	if err := s.save(); err != nil {
+		delete(s.recipes, r.ID)
+		return Recipe{}, err
+	}
+	return r, nil
+}
+
+func (s *fileStore) Update(ctx context.Context, r Recipe) (Recipe, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.recipes[r.ID]
+	if !ok {
+		return Recipe{}, ErrNotFound
+	}
+	r.CreatedAt, r.UpdatedAt = old.CreatedAt, time.Now().UTC()
+	s.recipes[r.ID] = r
+	if err := s.save(); err != nil {
+		s.recipes[r.ID] = old
+		return Recipe{}, err
+	}
+	return r, nil
+}
+
+func (s *fileStore) Put(ctx context.Context, r Recipe) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, exists := s.recipes[r.ID]
+	r.CreatedAt, r.UpdatedAt = old.CreatedAt, time.Now().UTC()
+	if !exists {
+		r.CreatedAt = r.UpdatedAt
+	}
+	s.recipes[r.ID] = r
+	if err := s.save(); err != nil {
+		if exists {
+			s.recipes[r.ID] = old
+		} else {
+			delete(s.recipes, r.ID)
+		}
+		return false, err
+	}
+	return !exists, nil
+}
+
+func (s *fileStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.recipes[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.recipes, id)
+	if err := s.save(); err != nil {
+		s.recipes[id] = old
+		return err
+	}
+	return nil
+}
+
+func (s *fileStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *fileStore) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fixture files are JSON, holding one recipe or an array of them, or
+// NDJSON with one recipe per line. Files named explicitly are read whatever
+// their extension; directories are searched for these.
+var ndjsonExts = map[string]bool{".ndjson": true, ".jsonl": true}
+
+func isFixtureFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return !strings.HasPrefix(name, ".") && (ext == ".json" || ndjsonExts[ext])
+}
+
+// fixtureError is a problem with one record of a fixture file
+type fixtureError struct {
+	path string
+	line int
+	err  error
+}
+
+func (e *fixtureError) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.path, e.line, e.err)
+}
+
+func (e *fixtureError) Unwrap() error {
+	return e.err
+}
+
+// fixture is a recipe read from a fixture file, with where it came from
+type fixture struct {
+	Recipe
+	path string
+	line int
+}
+
+// fixtureLoader stores the recipes of a set of fixture files and, while
+// watching, keeps the store in line with them as the files change.
+//
+// Each recipe is stored with its FixtureSource, so that a load only writes
+// the recipes whose fixture changed, even after a restart, and deletes
+// those whose fixture is gone, even if it went while the service was down.
+// A recipe changed through the API has no source and is left alone; one
+// deleted through the API is stored again at the next load.
+type fixtureLoader struct {
+	paths []string
+	store RecipeStore
+
+	// stamp describes the files last loaded, to tell when they change
+	stamp string
+}
+
+// fixtureHash sums up the content of a recipe, leaving out what the store
+// sets
+func fixtureHash(r Recipe) string {
+	r.CreatedAt, r.UpdatedAt, r.Fixture = time.Time{}, time.Time{}, nil
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// files lists the fixture files under the configured paths, sorted and
+// without duplicates
+func (l *fixtureLoader) files() ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, root := range l.paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || (path != root && !isFixtureFile(d.Name())) || seen[path] {
+				return nil
+			}
+			seen[path] = true
+			files = append(files, path)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fixtures: %w", err)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// fingerprint sums up the names, sizes and modification times of files, so
+// that editing, adding or removing any of them changes it
+func fingerprint(files []string) string {
+	var b strings.Builder
+	for _, path := range files {
+		b.WriteString(path)
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, " %d %d", info.Size(), info.ModTime().UnixNano())
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// load reads every fixture file and, if they are all valid, stores new and
+// changed recipes and deletes those that were dropped from the files since
+// the last load. When any file has errors nothing is stored, and the
+// errors are returned together, one per line.
+func (l *fixtureLoader) load(ctx context.Context) error {
+	files, err := l.files()
+	if err != nil {
+		return err
+	}
+	l.stamp = fingerprint(files)
+
+	var fixtures []fixture
+	var errs []error
+	for _, path := range files {
+		read, err := readFixtureFile(path)
+		fixtures = append(fixtures, read...)
+		errs = append(errs, err)
+	}
+
+	where := make(map[string]fixture, len(fixtures))
+	for _, f := range fixtures {
+		if first, ok := where[f.ID]; ok {
+			errs = append(errs, &fixtureError{f.path, f.line, fmt.Errorf("duplicate id %q, first used at %s:%d", f.ID, first.path, first.line)})
+			continue
+		}
+		where[f.ID] = f
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	if err := l.apply(ctx, where); err != nil {
+		// Try again on the next check even if the files stay the same
+		l.stamp = ""
+		return err
+	}
+	return nil
+}
+
+// apply brings the store in line with fixtures, by ID
+func (l *fixtureLoader) apply(ctx context.Context, fixtures map[string]fixture) error {
+	stored, err := l.store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("listing recipes: %w", err)
+	}
+	current := make(map[string]Recipe, len(stored))
+	for _, r := range stored {
+		current[r.ID] = r
+	}
+
+	var created, updated, deleted int
+	for id, f := range fixtures {
+		r := f.Recipe
+		r.Fixture = &FixtureSource{File: f.path, Hash: fixtureHash(r)}
+		if old, ok := current[id]; ok {
+			switch {
+			case old.Fixture != nil && *old.Fixture == *r.Fixture:
+				continue
+			case old.Fixture == nil && fixtureHash(old) != r.Fixture.Hash:
+				// Changed through the API; a recipe stored before sources
+				// were kept, and still as in its fixture, is taken over
+				log.Printf("Fixtures: %s:%d: recipe %s was changed through the API, keeping it", f.path, f.line, id)
+				continue
+			}
+		}
+		isNew, err := l.store.Put(ctx, r)
+		if err != nil {
+			return fmt.Errorf("storing fixture %s: %w", id, err)
+		}
+		if isNew {
+			created++
+		} else {
+			updated++
+		}
+	}
+	for id, r := range current {
+		if _, ok := fixtures[id]; ok || r.Fixture == nil {
+			continue
+		}
+		if err := l.store.Delete(ctx, id); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("deleting fixture %s: %w", id, err)
+		}
+		deleted++
+	}
+
+	log.Printf("Fixtures: %d recipe(s), %d created, %d updated, %d deleted", len(fixtures), created, updated, deleted)
+	return nil
+}
+
+// watch reloads the fixtures every interval when the files have changed,
+// until ctx is cancelled. Files with errors are reported and the store is
+// left as it was until they are fixed.
+func (l *fixtureLoader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		files, err := l.files()
+		if err != nil {
+			log.Printf("Reloading fixtures: %v", err)
+			continue
+		}
+		if fingerprint(files) == l.stamp {
+			continue
+		}
+		loadCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		err = l.load(loadCtx)
+		cancel()
+		if err != nil {
+			log.Printf("Reloading fixtures, keeping the previous ones:\n%v", err)
+		}
+	}
+}
+
+// readFixtureFile reads the recipes in one file. It carries on past bad
+// records so that they are all reported, except when the file isn't
+// well-formed JSON, where reading stops at the first syntax error.
+func readFixtureFile(path string) ([]fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fixtures: %w", err)
+	}
+
+	var fixtures []fixture
+	var errs []error
+	add := func(raw []byte, start int) {
+		r, err := decodeFixture(raw)
+		if err != nil {
+			errs = append(errs, &fixtureError{path, lineAt(data, start+errorOffset(err)), err})
+			return
+		}
+		fixtures = append(fixtures, fixture{r, path, lineAt(data, start)})
+	}
+
+	if ndjsonExts[strings.ToLower(filepath.Ext(path))] {
+		start := 0
+		for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+			if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+				add(trimmed, start+bytes.Index(line, trimmed))
+			}
+			start += len(line)
+		}
+		return fixtures, errors.Join(errs...)
+	}
+
+	start := skipSpace(data, 0, "")
+	if start == len(data) {
+		return nil, &fixtureError{path, 1, errors.New("empty file")}
+	}
+	if data[start] != '[' {
+		add(data[start:], start)
+		return fixtures, errors.Join(errs...)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.Token() // [
+	for dec.More() {
+		start := skipSpace(data, int(dec.InputOffset()), ",")
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			errs = append(errs, &fixtureError{path, lineAt(data, errorOffset(err)), err})
+			return fixtures, errors.Join(errs...)
+		}
+		add(raw, start)
+	}
+	if _, err := dec.Token(); err != nil {
+		errs = append(errs, &fixtureError{path, lineAt(data, errorOffset(err)), err})
+	}
+	return fixtures, errors.Join(errs...)
+}
+
+// decodeFixture decodes and validates one recipe. Records in the format of
+// the Spoonacular API, recognised by their extendedIngredients, are
+// converted; anything else must match Recipe exactly.
+func decodeFixture(raw []byte) (Recipe, error) {
+	var probe struct {
+		ExtendedIngredients json.RawMessage `json:"extendedIngredients"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return Recipe{}, err
+	}
+
+	var r Recipe
+	if probe.ExtendedIngredients != nil {
+		var err error
+		if r, err = fromSpoonacular(raw); err != nil {
+			return Recipe{}, err
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&r); err != nil {
+			return Recipe{}, err
+		}
+	}
+
+	r.ID = strings.TrimSpace(r.ID)
+	if r.ID == "" {
+		return Recipe{}, errors.New("id is required")
+	}
+	if err := r.validate(); err != nil {
+		return Recipe{}, err
+	}
+	r.CreatedAt, r.UpdatedAt, r.Fixture = time.Time{}, time.Time{}, nil
+	return r, nil
+}
+
+// spoonacularRecipe is the part of a Spoonacular recipe that is kept
+type spoonacularRecipe struct {
+	ID                  int      `json:"id"`
+	Title               string   `json:"title"`
+	Image               string   `json:"image"`
+	DishTypes           []string `json:"dishTypes"`
+	Vegetarian          bool     `json:"vegetarian"`
+	Vegan               bool     `json:"vegan"`
+	GlutenFree          bool     `json:"glutenFree"`
+	DairyFree           bool     `json:"dairyFree"`
+	ExtendedIngredients []struct {
+		Original string `json:"original"`
+	} `json:"extendedIngredients"`
+	Instructions         string `json:"instructions"`
+	AnalyzedInstructions []struct {
+		Steps []struct {
+			Step string `json:"step"`
+		} `json:"steps"`
+	} `json:"analyzedInstructions"`
+}
+
+// htmlTag matches the markup Spoonacular leaves in instructions
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// fromSpoonacular converts a recipe from the Spoonacular API
+func fromSpoonacular(raw []byte) (Recipe, error) {
+	var s spoonacularRecipe
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return Recipe{}, err
+	}
+
+	r := Recipe{
+		Title:              s.Title,
+		PhotoURL:           s.Image,
+		DietaryRestriction: []string{},
+	}
+	if s.ID != 0 {
+		r.ID = strconv.Itoa(s.ID)
+	}
+	for _, ingredient := range s.ExtendedIngredients {
+		r.Ingredients = append(r.Ingredients, ingredient.Original)
+	}
+
+	// Number the analyzed steps, or fall back to the free text
+	var steps []string
+	for _, block := range s.AnalyzedInstructions {
+		for _, step := range block.Steps {
+			steps = append(steps, fmt.Sprintf("%d. %s", len(steps)+1, strings.TrimSpace(step.Step)))
+		}
+	}
+	if len(steps) > 0 {
+		r.Instructions = strings.Join(steps, "\n")
+	} else {
+		r.Instructions = strings.TrimSpace(htmlTag.ReplaceAllString(s.Instructions, "\n"))
+	}
+
+	for _, dishType := range s.DishTypes {
+		switch dishType = strings.ToLower(dishType); dishType {
+		case "breakfast", "lunch", "dinner", "snack":
+			r.MealType = strings.ToUpper(dishType[:1]) + dishType[1:]
+		case "main course", "main dish":
+			r.MealType = "Dinner"
+		default:
+			continue
+		}
+		break
+	}
+
+	for _, diet := range []struct {
+		ok   bool
+		name string
+	}{
+		{s.Vegetarian, "Vegetarian"},
+		{s.Vegan, "Vegan"},
+		{s.GlutenFree, "Gluten-free"},
+		{s.DairyFree, "Dairy-free"},
+	} {
+		if diet.ok {
+			r.DietaryRestriction = append(r.DietaryRestriction, diet.name)
+		}
+	}
+	return r, nil
+}
+
+// errorOffset returns the byte offset a JSON error points at, or 0
+func errorOffset(err error) int {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return int(syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		return int(typeErr.Offset)
+	}
+	return 0
+}
+
+// lineAt returns the 1-based line of data that offset falls on
+func lineAt(data []byte, offset int) int {
+	offset = min(max(offset, 0), len(data))
+	return 1 + bytes.Count(data[:offset], []byte("\n"))
+}
+
+// skipSpace returns the offset of the first byte from i on that is neither
+// white space nor one of extra
+func skipSpace(data []byte, i int, extra string) int {
+	for i < len(data) && strings.IndexByte(" \t\r\n"+extra, data[i]) >= 0 {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFixtures replaces the fixture file in dir with recipes given as
+// id, title pairs
+func writeFixtures(t *testing.T, dir string, recipes ...string) {
+	t.Helper()
+	data := "["
+	for i := 0; i+1 < len(recipes); i += 2 {
+		if i > 0 {
+			data += ","
+		}
+		data += `{"id": "` + recipes[i] + `", "title": "` + recipes[i+1] + `", "ingredients": ["1 cup flour"], "instructions": "Mix."}`
+	}
+	if err := os.WriteFile(filepath.Join(dir, "recipes.json"), []byte(data+"]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// loadFixtures loads dir into store with a new loader, as at startup
+func loadFixtures(t *testing.T, store RecipeStore, dir string) {
+	t.Helper()
+	l := &fixtureLoader{paths: []string{dir}, store: store}
+	if err := l.load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFixtureRestarts(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := newFileStore(filepath.Join(t.TempDir(), "recipes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	title := func(id string) string {
+		t.Helper()
+		r, err := store.Get(ctx, id)
+		if err != nil {
+			return ""
+		}
+		return r.Title
+	}
+
+	writeFixtures(t, dir, "pancakes", "Pancakes", "bread", "Bread", "scones", "Scones")
+	loadFixtures(t, store, dir)
+	first, err := store.Get(ctx, "pancakes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Fixture == nil || first.Fixture.File != filepath.Join(dir, "recipes.json") || first.Fixture.Hash == "" {
+		t.Fatalf("fixture source = %+v", first.Fixture)
+	}
+
+	// Unchanged fixtures are not written again
+	loadFixtures(t, store, dir)
+	if again, _ := store.Get(ctx, "pancakes"); !again.UpdatedAt.Equal(first.UpdatedAt) {
+		t.Errorf("unchanged fixture was stored again")
+	}
+
+	// An edit through the API survives restarts, even when the fixture
+	// changes
+	edited, _ := store.Get(ctx, "bread")
+	edited.Title, edited.Fixture = "Sourdough", nil
+	if _, err := store.Update(ctx, edited); err != nil {
+		t.Fatal(err)
+	}
+
+	// While the service is down, bread changes, pancakes change and scones
+	// go away
+	writeFixtures(t, dir, "pancakes", "Crepes", "bread", "Rye bread")
+	loadFixtures(t, store, dir)
+	if got := title("pancakes"); got != "Crepes" {
+		t.Errorf("changed fixture: title = %q, want Crepes", got)
+	}
+	if got := title("bread"); got != "Sourdough" {
+		t.Errorf("edited recipe: title = %q, want Sourdough", got)
+	}
+	if got := title("scones"); got != "" {
+		t.Errorf("removed fixture is still stored as %q", got)
+	}
+
+	// Recipes created through the API are never deleted
+	if _, err := store.Create(ctx, Recipe{ID: "toast", Title: "Toast", Ingredients: []string{"bread"}}); err != nil {
+		t.Fatal(err)
+	}
+	writeFixtures(t, dir)
+	loadFixtures(t, store, dir)
+	if got := title("toast"); got != "Toast" {
+		t.Errorf("API recipe: title = %q, want Toast", got)
+	}
+	if got := title("bread"); got != "Sourdough" {
+		t.Errorf("edited recipe after its fixture went: title = %q, want Sourdough", got)
+	}
+}
+
+// Recipes stored before fixture sources were kept are taken over when
+// they still match their fixture
+func TestFixtureTakeOver(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := newFileStore(filepath.Join(t.TempDir(), "recipes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFixtures(t, dir, "pancakes", "Pancakes")
+
+	f, err := readFixtureFile(filepath.Join(dir, "recipes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Put(ctx, f[0].Recipe); err != nil {
+		t.Fatal(err)
+	}
+	loadFixtures(t, store, dir)
+	if r, _ := store.Get(ctx, "pancakes"); r.Fixture == nil {
+		t.Errorf("matching recipe was not taken over")
+	}
+
+	// Once taken over, it is deleted with its fixture
+	writeFixtures(t, dir)
+	loadFixtures(t, store, dir)
+	if _, err := store.Get(ctx, "pancakes"); err != ErrNotFound {
+		t.Errorf("get removed fixture: err = %v, want ErrNotFound", err)
+	}
+}
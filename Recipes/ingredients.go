@@ -0,0 +1,178 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"Service"
+)
+
+// Ingredient is an ingredient line of a recipe broken into its parts. Raw
+// is the line as written; the other fields are parsed from it and left
+// empty where the line doesn't say, as with "salt to taste".
+type Ingredient struct {
+	Raw      string  `json:"raw" bson:"raw"`
+	Quantity float64 `json:"quantity,omitempty" bson:"quantity,omitempty"`
+	// MaxQuantity is the upper end of a range such as "2-3 cloves"
+	MaxQuantity float64 `json:"max_quantity,omitempty" bson:"max_quantity,omitempty"`
+	// Unit is the canonical short form, such as "cup", "tbsp" or "g"
+	Unit  string `json:"unit,omitempty" bson:"unit,omitempty"`
+	Name  string `json:"name" bson:"name"`
+	Notes string `json:"notes,omitempty" bson:"notes,omitempty"`
+}
+
+// units maps the singular, lower case spellings of units to their
+// canonical form
+var units = map[string]string{
+	"cup": "cup", "c": "cup",
+	"tablespoon": "tbsp", "tbsp": "tbsp", "tbs": "tbsp", "tbl": "tbsp",
+	"teaspoon": "tsp", "tsp": "tsp",
+	"fluid ounce": "fl oz", "fl oz": "fl oz",
+	"ounce": "oz", "oz": "oz",
+	"pound": "lb", "lb": "lb", "lbs": "lb",
+	"gram": "g", "gramme": "g", "g": "g",
+	"kilogram": "kg", "kg": "kg",
+	"milliliter": "ml", "millilitre": "ml", "ml": "ml",
+	"liter": "l", "litre": "l", "l": "l",
+	"pint": "pint", "pt": "pint",
+	"quart": "quart", "qt": "quart",
+	"gallon": "gallon", "gal": "gallon",
+	"pinch": "pinch", "dash": "dash",
+	"clove": "clove", "slice": "slice", "stick": "stick", "piece": "piece",
+	"can": "can", "tin": "can", "jar": "jar", "bottle": "bottle",
+	"package": "package", "pkg": "package", "packet": "package",
+	"container": "container", "bag": "bag", "box": "box",
+	"bunch": "bunch", "head": "head", "sprig": "sprig",
+}
+
+// sizes are words describing the size of the ingredient, kept as notes
+var sizes = map[string]bool{"small": true, "medium": true, "large": true, "extra-large": true}
+
+// trailingNotes are phrases at the end of a line that describe how much to
+// use or what for, rather than the ingredient
+var trailingNotes = []string{"to taste", "for garnish", "for serving", "optional"}
+
+var (
+	// vulgarFractions spells out unicode fractions, so "1½" reads as "1 1/2"
+	vulgarFractions = strings.NewReplacer(
+		"½", " 1/2", "⅓", " 1/3", "⅔", " 2/3", "¼", " 1/4", "¾", " 3/4",
+		"⅕", " 1/5", "⅖", " 2/5", "⅗", " 3/5", "⅘", " 4/5", "⅙", " 1/6",
+		"⅚", " 5/6", "⅛", " 1/8", "⅜", " 3/8", "⅝", " 5/8", "⅞", " 7/8",
+		"⁄", "/",
+	)
+	parenthetical = regexp.MustCompile(`\(([^)]*)\)`)
+	// A quantity is a mixed number, fraction or decimal, or a range of two
+	amount          = `(\d+\s+\d+/\d+|\d+/\d+|\d*\.\d+|\d+)`
+	quantityPattern = regexp.MustCompile(`^` + amount + `(?:\s*(?:-|–|to)\s*` + amount + `)?\s*`)
+)
+
+// parseIngredients parses every line of a recipe's ingredients
+func parseIngredients(lines []string) []Ingredient {
+	parsed := make([]Ingredient, len(lines))
+	for i, line := range lines {
+		parsed[i] = parseIngredient(line)
+	}
+	return parsed
+}
+
+// parseIngredient breaks a line such as "1 1/2 cups fresh blueberries" or
+// "1 medium onion, diced" into quantity, unit, name and notes. Anything in
+// parentheses, after the first comma, or a size such as "large" goes into
+// the notes.
+func parseIngredient(line string) Ingredient {
+	in := Ingredient{Raw: line}
+	var notes []string
+
+	s := vulgarFractions.Replace(line)
+	s = parenthetical.ReplaceAllStringFunc(s, func(m string) string {
+		if note := strings.TrimSpace(m[1 : len(m)-1]); note != "" {
+			notes = append(notes, note)
+		}
+		return " "
+	})
+	s = strings.Join(strings.Fields(s), " ")
+
+	if m := quantityPattern.FindStringSubmatch(s); m != nil {
+		in.Quantity = parseAmount(m[1])
+		if m[2] != "" {
+			in.MaxQuantity = parseAmount(m[2])
+		}
+		s = s[len(m[0]):]
+	} else if article, rest, _ := strings.Cut(s, " "); strings.EqualFold(article, "a") || strings.EqualFold(article, "an") {
+		// "a pinch of salt" is one pinch
+		if unit, _ := parseUnit(rest); unit != "" {
+			in.Quantity, s = 1, rest
+		}
+	}
+
+	if unit, rest := parseUnit(s); unit != "" && rest != "" {
+		in.Unit, s = unit, rest
+	}
+	s = strings.TrimPrefix(s, "of ")
+
+	if first, rest, ok := strings.Cut(s, " "); ok && sizes[strings.ToLower(first)] {
+		notes = append([]string{strings.ToLower(first)}, notes...)
+		s = rest
+	}
+
+	if name, rest, ok := strings.Cut(s, ","); ok {
+		s = name
+		if rest = strings.TrimSpace(rest); rest != "" {
+			notes = append(notes, rest)
+		}
+	}
+	for _, phrase := range trailingNotes {
+		if lower := strings.ToLower(s); strings.HasSuffix(lower, " "+phrase) {
+			s = s[:len(s)-len(phrase)-1]
+			notes = append(notes, phrase)
+		}
+	}
+
+	in.Name = strings.TrimRight(strings.TrimSpace(s), ".;")
+	in.Notes = strings.Join(notes, ", ")
+	return in
+}
+
+// parseUnit reads the unit at the start of s, trying two-word units first,
+// and returns it in canonical form with the rest of s. The unit is empty
+// when s doesn't start with one.
+func parseUnit(s string) (unit, rest string) {
+	words := strings.SplitN(s, " ", 3)
+	key := func(w string) string {
+		return service.Singular(strings.TrimSuffix(strings.ToLower(w), "."))
+	}
+
+	if len(words) >= 2 {
+		if u, ok := units[key(words[0])+" "+key(words[1])]; ok {
+			return u, strings.Join(words[2:], " ")
+		}
+	}
+	rest = strings.Join(words[1:], " ")
+	// By convention a capital T is a tablespoon and a small one a teaspoon
+	switch strings.TrimSuffix(words[0], ".") {
+	case "T", "Tbsp":
+		return "tbsp", rest
+	case "t":
+		return "tsp", rest
+	}
+	return units[key(words[0])], rest
+}
+
+// parseAmount converts "1 1/2", "3/4", "0.5" or "2" to a number
+func parseAmount(s string) float64 {
+	var total float64
+	for _, part := range strings.Fields(s) {
+		if num, den, ok := strings.Cut(part, "/"); ok {
+			n, _ := strconv.ParseFloat(num, 64)
+			d, _ := strconv.ParseFloat(den, 64)
+			if d != 0 {
+				total += n / d
+			}
+			continue
+		}
+		n, _ := strconv.ParseFloat(part, 64)
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestParseIngredient(t *testing.T) {
+	tests := []struct {
+		line string
+		want Ingredient
+	}{
+		{"1/2 cup granulated sugar", Ingredient{Quantity: 0.5, Unit: "cup", Name: "granulated sugar"}},
+		{"1 1/2 cups fresh blueberries", Ingredient{Quantity: 1.5, Unit: "cup", Name: "fresh blueberries"}},
+		{"1/2 cup unsalted butter, melted", Ingredient{Quantity: 0.5, Unit: "cup", Name: "unsalted butter", Notes: "melted"}},
+		{"2 tablespoons olive oil", Ingredient{Quantity: 2, Unit: "tbsp", Name: "olive oil"}},
+		{"1 T. honey", Ingredient{Quantity: 1, Unit: "tbsp", Name: "honey"}},
+		{"1 t vanilla extract", Ingredient{Quantity: 1, Unit: "tsp", Name: "vanilla extract"}},
+		{"0.5 kg flour", Ingredient{Quantity: 0.5, Unit: "kg", Name: "flour"}},
+		{"200g dark chocolate", Ingredient{Quantity: 200, Unit: "g", Name: "dark chocolate"}},
+		{"8 fl oz milk", Ingredient{Quantity: 8, Unit: "fl oz", Name: "milk"}},
+		{"3 eggs", Ingredient{Quantity: 3, Name: "eggs"}},
+
+		// Unicode fractions
+		{"½ cup milk", Ingredient{Quantity: 0.5, Unit: "cup", Name: "milk"}},
+		{"1½ cups flour", Ingredient{Quantity: 1.5, Unit: "cup", Name: "flour"}},
+		{"2 ¾ cups broth", Ingredient{Quantity: 2.75, Unit: "cup", Name: "broth"}},
+		{"1⁄4 tsp nutmeg", Ingredient{Quantity: 0.25, Unit: "tsp", Name: "nutmeg"}},
+
+		// Ranges
+		{"2-3 cloves garlic, minced", Ingredient{Quantity: 2, MaxQuantity: 3, Unit: "clove", Name: "garlic", Notes: "minced"}},
+		{"1 to 2 tbsp sugar", Ingredient{Quantity: 1, MaxQuantity: 2, Unit: "tbsp", Name: "sugar"}},
+		{"½–¾ cup water", Ingredient{Quantity: 0.5, MaxQuantity: 0.75, Unit: "cup", Name: "water"}},
+
+		// To taste and other trailing notes
+		{"salt to taste", Ingredient{Name: "salt", Notes: "to taste"}},
+		{"Salt and pepper, to taste", Ingredient{Name: "Salt and pepper", Notes: "to taste"}},
+		{"a pinch of salt", Ingredient{Quantity: 1, Unit: "pinch", Name: "salt"}},
+		{"fresh parsley for garnish", Ingredient{Name: "fresh parsley", Notes: "for garnish"}},
+
+		// Parenthetical notes and sizes
+		{"1 (14 oz) can diced tomatoes", Ingredient{Quantity: 1, Unit: "can", Name: "diced tomatoes", Notes: "14 oz"}},
+		{"2 large eggs (room temperature)", Ingredient{Quantity: 2, Name: "eggs", Notes: "large, room temperature"}},
+		{"1 medium onion, diced", Ingredient{Quantity: 1, Name: "onion", Notes: "medium, diced"}},
+		{"4 ounces cream cheese (softened), cubed", Ingredient{Quantity: 4, Unit: "oz", Name: "cream cheese", Notes: "softened, cubed"}},
+
+		{"", Ingredient{}},
+	}
+	for _, tt := range tests {
+		tt.want.Raw = tt.line
+		if got := parseIngredient(tt.line); got != tt.want {
+			t.Errorf("parseIngredient(%q) =\n\t%+v, want\n\t%+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	for in, want := range map[string]float64{"2": 2, "0.5": 0.5, ".5": 0.5, "3/4": 0.75, "1 1/2": 1.5, "1/0": 0} {
+		if got := parseAmount(in); got != want {
+			t.Errorf("parseAmount(%q) = %g, want %g", in, got, want)
+		}
+	}
+}
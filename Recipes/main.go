@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Service"
+)
+
+// Recipe represents the JSON data structure
+type Recipe struct {
+	ID                 string   `json:"id" bson:"_id"`
+	Title              string   `json:"title" bson:"title"`
+	Ingredients        []string `json:"ingredients" bson:"ingredients"`
+	Instructions       string   `json:"instructions" bson:"instructions"`
+	PhotoURL           string   `json:"image" bson:"photo_url"`
+	MealType           string   `json:"dishTypes" bson:"meal_type"`
+	DietaryRestriction []string `json:"dietary_restriction" bson:"dietary_restriction"`
+
+	// ParsedIngredients are the Ingredients lines broken into quantity,
+	// unit, name and notes. They are worked out whenever a recipe is saved.
+	ParsedIngredients []Ingredient `json:"parsed_ingredients" bson:"parsed_ingredients"`
+	// Steps are the Instructions split into numbered steps, also worked
+	// out on save
+	Steps []Step `json:"steps" bson:"steps"`
+
+	// Fixture is where a recipe loaded from the fixtures came from. It is
+	// cleared when the recipe is changed through the API, after which the
+	// fixture no longer overwrites it.
+	Fixture *FixtureSource `json:"fixture,omitempty" bson:"fixture,omitempty"`
+
+	// Set by the store
+	CreatedAt time.Time `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+// FixtureSource is the fixture file a recipe was loaded from and a hash of
+// the recipe it held
+type FixtureSource struct {
+	File string `json:"file" bson:"file"`
+	Hash string `json:"hash" bson:"hash"`
+}
+
+// recipeConfig holds the recipe service settings. Values are read, in
+// increasing order of precedence, from the defaults below, the "recipes"
+// section of the shared config file, environment variables and
+// command-line flags.
+type recipeConfig struct {
+	Addr      string `json:"addr"`
+	PantryURL string `json:"pantry_url"`
+	// PantryToken authenticates to the pantry service, if it requires it
+	PantryToken string `json:"pantry_token"`
+
+	// Store is where recipes are kept: a JSON file or MongoDB
+	Store    string `json:"store"`
+	File     string `json:"file"`
+	MongoURI string `json:"mongo_uri"`
+	Database string `json:"database"`
+
+	// Fixtures are recipe files, or directories of them, loaded into the
+	// store at startup. FixtureReload is how often they are checked for
+	// changes; 0 loads them only once.
+	Fixtures      []string         `json:"fixtures"`
+	FixtureReload service.Duration `json:"fixture_reload"`
+
+	// DrainGrace is how long the service keeps serving, while reporting
+	// not ready, after it is told to shut down
+	DrainGrace service.Duration `json:"drain_grace"`
+}
+
+var config = recipeConfig{
+	Addr:      "localhost:8081",
+	PantryURL: "http://localhost:9000",
+	Store:     "file",
+	File:      "recipes.json",
+	MongoURI:  "mongodb://localhost:27017",
+	Database:  "recipes",
+	Fixtures:  []string{"fixtures"},
+
+	DrainGrace: service.Duration(service.DefaultDrainGrace),
+}
+
+// draining is set once the server has started shutting down
+var draining atomic.Bool
+
+// loadConfig fills in config from every source and validates it
+func loadConfig(args []string) error {
+	settings := service.NewSettings("recipes", "recipes", &config)
+	settings.String(&config.Addr, "RECIPES_ADDR", "addr", "address to listen on")
+	settings.String(&config.PantryURL, "RECIPES_PANTRY_URL", "pantry", "base URL of the pantry service, for ingredient aliases")
+	settings.String(&config.PantryToken, "RECIPES_PANTRY_TOKEN", "", "")
+	settings.String(&config.Store, "RECIPES_STORE", "store", "storage backend: file or mongo")
+	settings.String(&config.File, "RECIPES_FILE", "file", "path of the recipe file, for the file store")
+	settings.String(&config.MongoURI, "RECIPES_MONGO_URI", "mongo", "MongoDB connection URI")
+	settings.String(&config.Database, "RECIPES_DATABASE", "database", "MongoDB database name")
+	settings.List(&config.Fixtures, "RECIPES_FIXTURES", "fixtures", "comma-separated recipe files or directories to load at startup")
+	settings.Duration(&config.FixtureReload, "RECIPES_FIXTURE_RELOAD", "fixture-reload", "how often to reload changed fixtures; 0 disables reloading")
+	settings.Duration(&config.DrainGrace, "RECIPES_DRAIN_GRACE", "drain-grace", "how long to keep serving, reporting not ready, before shutting down")
+	if _, err := settings.Load(args); err != nil {
+		return err
+	}
+
+	// Validate
+	var errs []error
+	if err := service.CheckAddr("addr", config.Addr); err != nil {
+		errs = append(errs, err)
+	}
+	if err := service.CheckURL("pantry_url", &config.PantryURL); err != nil {
+		errs = append(errs, err)
+	}
+	switch config.Store {
+	case "file":
+		if config.File == "" {
+			errs = append(errs, errors.New("file must not be empty"))
+		}
+	case "mongo":
+		if !strings.HasPrefix(config.MongoURI, "mongodb://") && !strings.HasPrefix(config.MongoURI, "mongodb+srv://") {
+			errs = append(errs, fmt.Errorf("mongo_uri %q must start with mongodb:// or mongodb+srv://", config.MongoURI))
+		}
+		if config.Database == "" {
+			errs = append(errs, errors.New("database must not be empty"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("store %q must be file or mongo", config.Store))
+	}
+	if config.FixtureReload < 0 {
+		errs = append(errs, errors.New("fixture_reload must not be negative"))
+	}
+	if config.DrainGrace < 0 {
+		errs = append(errs, errors.New("drain_grace must not be negative"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	if err := loadConfig(os.Args[1:]); err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	// Open the recipe store
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	store, err := openStore(ctx)
+	cancel()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the fixtures, and keep them up to date if asked to
+	fixtures := &fixtureLoader{paths: config.Fixtures, store: store}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	err = fixtures.load(ctx)
+	cancel()
+	if err != nil {
+		log.Fatalf("Loading fixtures:\n%v", err)
+	}
+	// The watcher is stopped before the store is closed
+	ctx, cancel = context.WithCancel(context.Background())
+	var watcher sync.WaitGroup
+	if config.FixtureReload > 0 {
+		watcher.Add(1)
+		go func() {
+			defer watcher.Done()
+			fixtures.watch(ctx, time.Duration(config.FixtureReload))
+		}()
+	}
+	rs := &recipeService{store: store}
+
+	mux := http.NewServeMux()
+	// Search and details, used by the web frontend
+	mux.HandleFunc("GET /recipe", rs.recipeHandler)
+	mux.HandleFunc("OPTIONS /recipe", rs.recipeHandler)
+	mux.HandleFunc("GET /details", rs.detailHandler)
+
+	// Recipe management
+	mux.HandleFunc("GET /recipes", rs.listRecipes)
+	mux.HandleFunc("POST /recipes", rs.createRecipe)
+	mux.HandleFunc("GET /recipes/{id}", rs.getRecipe)
+	mux.HandleFunc("PUT /recipes/{id}", rs.updateRecipe)
+	mux.HandleFunc("DELETE /recipes/{id}", rs.deleteRecipe)
+	mux.HandleFunc("POST /convert/spoonacular", rs.convertSpoonacular)
+
+	// The recipes can be served without the pantry, whose aliases are
+	// only used to widen searches, so it doesn't affect readiness
+	mux.HandleFunc("GET /healthz", service.Healthz)
+	mux.HandleFunc("GET /readyz", service.Readyz(&draining,
+		service.Check{Name: "store", Run: store.Ping},
+		service.Check{Name: "pantry", Run: service.Upstream(config.PantryURL), Informational: true},
+	))
+
+	log.Printf("Recipe service started on %s (%s store)", config.Addr, config.Store)
+	err = service.ListenAndServe(&http.Server{Addr: config.Addr, Handler: mux}, &draining, time.Duration(config.DrainGrace))
+	cancel()
+	watcher.Wait()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	if err := store.Close(ctx); err != nil {
+		log.Printf("Closing store: %v", err)
+	}
+	cancel()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// mongoStore keeps the recipes in the recipes collection of the configured
+// database, with the recipe ID as the document ID
+type mongoStore struct {
+	client     *mongo.Client
+	collection *mongo.Collection
+}
+
+func newMongoStore(ctx context.Context, uri, databaseName string) (*mongoStore, error) {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	return &mongoStore{
+		client:     client,
+		collection: client.Database(databaseName).Collection("recipes"),
+	}, nil
+}
+
+func (s *mongoStore) List(ctx context.Context) ([]Recipe, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := s.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	recipes := []Recipe{}
+	if err := cursor.All(ctx, &recipes); err != nil {
+		return nil, err
+	}
+	return recipes, nil
+}
+
+func (s *mongoStore) Get(ctx context.Context, id string) (Recipe, error) {
+	var r Recipe
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&r)
+	if err == mongo.ErrNoDocuments {
+		return Recipe{}, ErrNotFound
+	}
+	return r, err
+}
+
+func (s *mongoStore) Create(ctx context.Context, r Recipe) (Recipe, error) {
+	now := time.Now().UTC()
+	r.CreatedAt, r.UpdatedAt = now, now
+	if _, err := s.collection.InsertOne(ctx, r); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Recipe{}, ErrExists
+		}
+		return Recipe{}, err
+	}
+	return r, nil
+}
+
+func (s *mongoStore) Update(ctx context.Context, r Recipe) (Recipe, error) {
+	r.UpdatedAt = time.Now().UTC()
+
+	// Replace everything but the creation time, and read that back
+	update := bson.M{"$set": bson.M{
+		"title":               r.Title,
+		"ingredients":         r.Ingredients,
+		"parsed_ingredients":  r.ParsedIngredients,
+		"instructions":        r.Instructions,
+		"steps":               r.Steps,
+		"photo_url":           r.PhotoURL,
+		"meal_type":           r.MealType,
+		"dietary_restriction": r.DietaryRestriction,
+		"fixture":             r.Fixture,
+		"updated_at":          r.UpdatedAt,
+	}}
+	var updated Recipe
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.collection.FindOneAndUpdate(ctx, bson.M{"_id": r.ID}, update, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return Recipe{}, ErrNotFound
+	}
+	return updated, err
+}
+
+func (s *mongoStore) Put(ctx context.Context, r Recipe) (bool, error) {
+	now := time.Now().UTC()
+	update := bson.M{
+		"$set": bson.M{
+			"title":               r.Title,
+			"ingredients":         r.Ingredients,
+			"parsed_ingredients":  r.ParsedIngredients,
+			"instructions":        r.Instructions,
+			"steps":               r.Steps,
+			"photo_url":           r.PhotoURL,
+			"meal_type":           r.MealType,
+			"dietary_restriction": r.DietaryRestriction,
+			"fixture":             r.Fixture,
+			"updated_at":          now,
+		},
+		"$setOnInsert": bson.M{"created_at": now},
+	}
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": r.ID}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+func (s *mongoStore) Delete(ctx context.Context, id string) error {
+	result, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mongoStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx, readpref.Primary())
+}
+
+func (s *mongoStore) Close(ctx context.Context) error {
+	return s.client.Disconnect(ctx)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+func (rs *recipeService) recipeHandler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")             // Allow requests from any origin
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS") // Allow GET and OPTIONS methods
+
+	// Check if the request method is OPTIONS (preflight request)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	// Handle GET request to /recipe
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// Parse the meal type, dietary restriction, and ingredients from the query parameters
+	mealType := r.URL.Query().Get("meal_type")
+	dietaryRestrictions := r.URL.Query()["dietary_restriction"]
+	ingredients := r.URL.Query().Get("ingredients")
+
+	// Load the recipes from the store
+	ctx, cancel := context.WithTimeout(r.Context(), storeTimeout)
+	defer cancel()
+	recipes, err := rs.store.List(ctx)
+	if err != nil {
+		http.Error(w, "Failed to load recipes", http.StatusInternalServerError)
+		return
+	}
+
+	// Initialize a slice to store matching recipes
+	var matchingRecipes []Recipe
+
+	// Iterate over the recipes
+	for _, recipe := range recipes {
+		// Check if the meal type matches the query or the query is empty
+		if mealType == "" || strings.EqualFold(mealType, "none") || strings.EqualFold(recipe.MealType, mealType) {
+			// Check if any of the recipe's dietary restrictions match any of the dietary restrictions specified in the query
+			if len(dietaryRestrictions) == 0 {
+				// If no dietary restrictions are specified in the query, add the recipe to the matching recipes slice
+				if containsIngredients(recipe, ingredients) {
+					matchingRecipes = append(matchingRecipes, recipe)
+				}
+			} else {
+				// Iterate over the dietary restrictions specified in the query
+				for _, restriction := range dietaryRestrictions {
+					// Check if the recipe has the current dietary restriction
+					if recipeHasDietaryRestriction(recipe, restriction) && containsIngredients(recipe, ingredients) {
+						// If the recipe has the dietary restriction and contains the ingredients, add it to the matching recipes slice
+						matchingRecipes = append(matchingRecipes, recipe)
+						// Break out of the loop since the recipe has already been added
+						break
+					}
+				}
+			}
+		}
+	}
+
+	// Check if any matching recipes were found
+	if len(matchingRecipes) == 0 {
+		http.Error(w, "No recipes found matching the search criteria", http.StatusNotFound)
+		return
+	}
+
+	// Marshal the matching recipes slice into JSON format
+	recipesJSON, err := json.Marshal(matchingRecipes)
+	if err != nil {
+		http.Error(w, "Failed to marshal recipes JSON", http.StatusInternalServerError)
+		return
+	}
+
+	// Set the content type header
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write the JSON response
+	w.Write(recipesJSON)
+}
+
+func recipeHasDietaryRestriction(recipe Recipe, restriction string) bool {
+	// If the restriction is "None" or blank, consider it as no restriction
+	if strings.TrimSpace(strings.ToLower(restriction)) == "none" || restriction == "" {
+		return true
+	}
+
+	// Iterate over the recipe's dietary restrictions
+	for _, r := range recipe.DietaryRestriction {
+		// Check if the current dietary restriction matches the specified restriction (case-insensitive)
+		if strings.EqualFold(strings.TrimSpace(r), strings.TrimSpace(restriction)) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIngredients(recipe Recipe, ingredients string) bool {
+	if ingredients == "" {
+		return true // If no ingredients are specified, all recipes are considered to contain the ingredients
+	}
+
+	// Resolve aliases on both sides, so "scallion" finds "green onions"
+	aliases := pantryAliases.get()
+
+	// Split the required ingredients string into individual words
+	requiredIngredients := strings.Fields(aliases.canonicalize(ingredients))
+
+	// Convert both the recipe's ingredients and the required ingredients to lowercase for case-insensitive comparison
+	recipeIngredientsLower := aliases.canonicalize(strings.Join(recipe.Ingredients, " "))
+
+	// Check if all the required ingredients are found in the text of the recipe's ingredients
+	for _, ingredient := range requiredIngredients {
+		if !strings.Contains(recipeIngredientsLower, strings.ToLower(ingredient)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Step is one numbered step of a recipe's instructions, with the time and
+// oven temperature it mentions, if any
+type Step struct {
+	Number int    `json:"number" bson:"number"`
+	Text   string `json:"text" bson:"text"`
+	// Duration is in seconds; MaxDuration is the upper end of a range such
+	// as "20 to 25 minutes"
+	Duration    int          `json:"duration,omitempty" bson:"duration,omitempty"`
+	MaxDuration int          `json:"max_duration,omitempty" bson:"max_duration,omitempty"`
+	Temperature *Temperature `json:"temperature,omitempty" bson:"temperature,omitempty"`
+}
+
+// Temperature is a temperature in the unit the recipe gives it in, "F" or
+// "C"
+type Temperature struct {
+	Value float64 `json:"value" bson:"value"`
+	Unit  string  `json:"unit" bson:"unit"`
+}
+
+var (
+	// stepNumber matches the "1." or "2)" that starts a numbered step
+	stepNumber = regexp.MustCompile(`(?i)^\s*(?:step\s*)?\d+\s*[.):](?:\s+|$)`)
+	// listItem and listEnd match the tags around an item of an HTML list,
+	// whose closing tag may be left out
+	listItem = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	listEnd  = regexp.MustCompile(`(?i)</(?:li|ol|ul)\s*>`)
+	// blockTag matches tags that end a line of text
+	blockTag = regexp.MustCompile(`(?i)</?(?:p|div|br|ol|ul|h\d)\b[^>]*>`)
+	anyTag   = regexp.MustCompile(`<[^>]*>`)
+
+	// durationPattern takes the same amounts as ingredient quantities, so
+	// "1 1/2 hours" is read whole
+	durationPattern = regexp.MustCompile(`(?i)\b` + amount + `(?:\s*(?:-|–|to)\s*` + amount + `)?\s*(seconds?|secs?|minutes?|mins?|hours?|hrs?)\b`)
+	// tempPattern matches "375°F", "180 degrees C", "200 Celsius" and a bare
+	// "180 C"; a bare unit must be a capital so "2 c flour" isn't read as a
+	// temperature
+	tempPattern = regexp.MustCompile(`(?i)\b(\d+(?:\.\d+)?)\s*(?:(?:°\s*|degrees?\s+)(f|c)(?:ahrenheit|elsius)?|(f)ahrenheit|(c)elsius|(?-i:([FC])))\b`)
+)
+
+// parseSteps splits instructions into steps. Plain text is split on lines
+// numbered "1." or "2)", with unnumbered lines after them continuing the
+// step, or else one step per line. HTML, as the Spoonacular API returns,
+// gives one step per list item, or is treated as text with one line per
+// paragraph. Steps are renumbered from 1.
+func parseSteps(instructions string) []Step {
+	var texts []string
+	if items := listItems(instructions); items != nil {
+		for _, item := range items {
+			texts = append(texts, stripTags(item))
+		}
+	} else {
+		texts = splitNumbered(stripTags(blockTag.ReplaceAllString(instructions, "\n")))
+	}
+
+	steps := []Step{}
+	for _, text := range texts {
+		if text = strings.Join(strings.Fields(text), " "); text != "" {
+			steps = append(steps, newStep(len(steps)+1, text))
+		}
+	}
+	return steps
+}
+
+// listItems returns the contents of the items of the HTML lists in s, or
+// nil if it has none. An item runs to its closing tag, or else to the next
+// item or the end of its list.
+func listItems(s string) []string {
+	starts := listItem.FindAllStringIndex(s, -1)
+	if starts == nil {
+		return nil
+	}
+	items := make([]string, len(starts))
+	for i, loc := range starts {
+		end := len(s)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		item := s[loc[1]:end]
+		if stop := listEnd.FindStringIndex(item); stop != nil {
+			item = item[:stop[0]]
+		}
+		items[i] = item
+	}
+	return items
+}
+
+// splitNumbered splits text into steps at numbered lines, or at every line
+// when none is numbered
+func splitNumbered(text string) []string {
+	lines := strings.Split(text, "\n")
+	numbered := false
+	for _, line := range lines {
+		if stepNumber.MatchString(line) {
+			numbered = true
+			break
+		}
+	}
+	if !numbered {
+		return lines
+	}
+
+	var steps []string
+	for _, line := range lines {
+		if loc := stepNumber.FindStringIndex(line); loc != nil || len(steps) == 0 {
+			if loc != nil {
+				line = line[loc[1]:]
+			}
+			steps = append(steps, line)
+			continue
+		}
+		steps[len(steps)-1] += " " + line
+	}
+	return steps
+}
+
+// stripTags removes HTML tags and decodes entities
+func stripTags(s string) string {
+	return html.UnescapeString(anyTag.ReplaceAllString(s, " "))
+}
+
+// newStep builds a step, picking out the first time and temperature in its
+// text
+func newStep(number int, text string) Step {
+	step := Step{Number: number, Text: text}
+
+	if m := durationPattern.FindStringSubmatch(text); m != nil {
+		unit := durationUnit(m[3])
+		step.Duration = seconds(m[1], unit)
+		if m[2] != "" {
+			step.MaxDuration = seconds(m[2], unit)
+		}
+	}
+	if m := tempPattern.FindStringSubmatch(text); m != nil {
+		value, _ := strconv.ParseFloat(m[1], 64)
+		unit := strings.ToUpper(strings.Join(m[2:], ""))
+		step.Temperature = &Temperature{Value: value, Unit: unit}
+	}
+	return step
+}
+
+// durationUnit returns the length in seconds of a unit of time as written
+// in a recipe
+func durationUnit(unit string) int {
+	switch unit = strings.ToLower(unit); {
+	case strings.HasPrefix(unit, "h"):
+		return 3600
+	case strings.HasPrefix(unit, "m"):
+		return 60
+	}
+	return 1
+}
+
+func seconds(amount string, unit int) int {
+	return int(parseAmount(amount) * float64(unit))
+}
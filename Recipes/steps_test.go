@@ -0,0 +1,109 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSteps(t *testing.T) {
+	tests := []struct {
+		name         string
+		instructions string
+		want         []Step
+	}{
+		{
+			name:         "numbered text",
+			instructions: "1. Preheat the oven to 375°F.\n2. Mix the flour and sugar.\n3) Bake for 20 to 25 minutes.",
+			want: []Step{
+				{Number: 1, Text: "Preheat the oven to 375°F.", Temperature: &Temperature{375, "F"}},
+				{Number: 2, Text: "Mix the flour and sugar."},
+				{Number: 3, Text: "Bake for 20 to 25 minutes.", Duration: 1200, MaxDuration: 1500},
+			},
+		},
+		{
+			name:         "numbered text with continuation lines",
+			instructions: "Step 1: Boil the water.\nAdd salt.\n\nStep 2: Cook the pasta for 10 mins.",
+			want: []Step{
+				{Number: 1, Text: "Boil the water. Add salt."},
+				{Number: 2, Text: "Cook the pasta for 10 mins.", Duration: 600},
+			},
+		},
+		{
+			name:         "unnumbered lines",
+			instructions: "Chop the onion.\n\nFry it for 1.5 hours.\n",
+			want: []Step{
+				{Number: 1, Text: "Chop the onion."},
+				{Number: 2, Text: "Fry it for 1.5 hours.", Duration: 5400},
+			},
+		},
+		{
+			name:         "mixed numbers and fractions",
+			instructions: "1. Simmer for 1 1/2 hours.\n2. Rest for 1/2 to 3/4 hour.",
+			want: []Step{
+				{Number: 1, Text: "Simmer for 1 1/2 hours.", Duration: 5400},
+				{Number: 2, Text: "Rest for 1/2 to 3/4 hour.", Duration: 1800, MaxDuration: 2700},
+			},
+		},
+		{
+			name:         "html list items",
+			instructions: "<ol><li>Heat the oven to 180 C.</li><li>Whisk the <b>eggs</b> &amp; milk.</li><li></li><li>Bake 30-35 min</li></ol>",
+			want: []Step{
+				{Number: 1, Text: "Heat the oven to 180 C.", Temperature: &Temperature{180, "C"}},
+				{Number: 2, Text: "Whisk the eggs & milk."},
+				{Number: 3, Text: "Bake 30-35 min", Duration: 1800, MaxDuration: 2100},
+			},
+		},
+		{
+			name:         "html list items without closing tags",
+			instructions: "<ul><li>Rest for 45 seconds<li>Serve",
+			want: []Step{
+				{Number: 1, Text: "Rest for 45 seconds", Duration: 45},
+				{Number: 2, Text: "Serve"},
+			},
+		},
+		{
+			name:         "html paragraphs",
+			instructions: "<p>Roast at 200 degrees Celsius.</p><p>Let it cool.</p>",
+			want: []Step{
+				{Number: 1, Text: "Roast at 200 degrees Celsius.", Temperature: &Temperature{200, "C"}},
+				{Number: 2, Text: "Let it cool."},
+			},
+		},
+		{
+			name:         "empty",
+			instructions: " \n ",
+			want:         []Step{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSteps(tt.instructions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSteps(%q) =\n\t%+v, want\n\t%+v", tt.instructions, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStepTemperature(t *testing.T) {
+	tests := []struct {
+		text string
+		want *Temperature
+	}{
+		{"Preheat to 350°F", &Temperature{350, "F"}},
+		{"Preheat to 350 ° f", &Temperature{350, "F"}},
+		{"Heat to 180 degrees C", &Temperature{180, "C"}},
+		{"Heat to 180 degree celsius", &Temperature{180, "C"}},
+		{"Heat to 400 Fahrenheit", &Temperature{400, "F"}},
+		{"Heat to 180 C", &Temperature{180, "C"}},
+		{"Heat to 180C", &Temperature{180, "C"}},
+		{"Heat to 425 F and bake", &Temperature{425, "F"}},
+		{"Add 2 c flour", nil},
+		{"Add 2 cups flour", nil},
+		{"Use 3 Cans of beans", nil},
+	}
+	for _, tt := range tests {
+		if got := newStep(1, tt.text).Temperature; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("temperature of %q = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
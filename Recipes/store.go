@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when a recipe does not exist
+	ErrNotFound = errors.New("recipe not found")
+	// ErrExists is returned when creating a recipe whose ID is taken
+	ErrExists = errors.New("recipe already exists")
+)
+
+// RecipeStore is the storage used by the recipe handlers. Implementations
+// must be safe for concurrent use.
+type RecipeStore interface {
+	// List returns every recipe, sorted by ID
+	List(ctx context.Context) ([]Recipe, error)
+	// Get returns the recipe with the given ID or ErrNotFound
+	Get(ctx context.Context, id string) (Recipe, error)
+	// Create stores a new recipe under its ID, or returns ErrExists. It
+	// sets the timestamps and returns the stored recipe.
+	Create(ctx context.Context, r Recipe) (Recipe, error)
+	// Update replaces the recipe with r's ID, or returns ErrNotFound. The
+	// creation time is kept, and the fixture source replaced with r's.
+	Update(ctx context.Context, r Recipe) (Recipe, error)
+	// Put stores r under its ID whether or not it exists, keeping the
+	// creation time of a recipe it replaces. It reports whether the recipe
+	// was created.
+	Put(ctx context.Context, r Recipe) (bool, error)
+	// Delete removes a recipe, or returns ErrNotFound
+	Delete(ctx context.Context, id string) error
+	// Ping checks that the store can be reached
+	Ping(ctx context.Context) error
+	// Close releases any resources held by the store
+	Close(ctx context.Context) error
+}
+
+// openStore connects to the storage backend selected in the config
+func openStore(ctx context.Context) (RecipeStore, error) {
+	switch config.Store {
+	case "file":
+		return newFileStore(config.File)
+	case "mongo":
+		return newMongoStore(ctx, config.MongoURI, config.Database)
+	default:
+		return nil, fmt.Errorf("unknown store %q (want file or mongo)", config.Store)
+	}
+}
+
+// newRecipeID returns a random recipe ID
+func newRecipeID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validate checks a recipe sent by a client and tidies it up: surrounding
+// spaces are trimmed and blank ingredients dropped. It also parses the
+// ingredient lines and instruction steps.
+func (r *Recipe) validate() error {
+	r.Title = strings.TrimSpace(r.Title)
+	if r.Title == "" {
+		return errors.New("title is required")
+	}
+
+	ingredients := []string{}
+	for _, ingredient := range r.Ingredients {
+		if ingredient = strings.TrimSpace(ingredient); ingredient != "" {
+			ingredients = append(ingredients, ingredient)
+		}
+	}
+	if len(ingredients) == 0 {
+		return errors.New("at least one ingredient is required")
+	}
+	r.Ingredients = ingredients
+	r.ParsedIngredients = parseIngredients(ingredients)
+	r.Steps = parseSteps(r.Instructions)
+
+	if r.DietaryRestriction == nil {
+		r.DietaryRestriction = []string{}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Settings reads a service's configuration from, in increasing order of
+// precedence, the defaults already in the target, the service's section of
+// the shared JSON config file, environment variables and command-line flags.
+// Each setting is declared once with the environment variable and flag that
+// override it; the config file is read into the target with encoding/json.
+type Settings struct {
+	flags      *flag.FlagSet
+	section    string
+	target     any
+	configPath *string
+	env        []envSetting
+	// flagSetters store the value of each flag, once the config file and
+	// environment have been applied, so flags take precedence over them
+	flagSetters map[string]func() error
+}
+
+type envSetting struct {
+	name string
+	set  func(string) error
+}
+
+// NewSettings starts the configuration of the service called name, whose
+// settings are in section of the config file and are read into target. The
+// config file is given with -config or CLOUDCUISINE_CONFIG.
+func NewSettings(name, section string, target any) *Settings {
+	s := &Settings{
+		flags:       flag.NewFlagSet(name, flag.ContinueOnError),
+		section:     section,
+		target:      target,
+		flagSetters: make(map[string]func() error),
+	}
+	s.configPath = s.flags.String("config", os.Getenv("CLOUDCUISINE_CONFIG"), "path to the shared JSON config file")
+	return s
+}
+
+// Flags returns the flag set, for setting its usage message
+func (s *Settings) Flags() *flag.FlagSet {
+	return s.flags
+}
+
+// Var declares a setting read from the environment variable env and the
+// flag called flagName, either of which may be empty. current formats the
+// value for the flag's default and set parses and stores a new one.
+func (s *Settings) Var(env, flagName, usage string, current string, set func(string) error) {
+	if env != "" {
+		s.env = append(s.env, envSetting{env, set})
+	}
+	if flagName != "" {
+		v := s.flags.String(flagName, current, usage)
+		s.flagSetters[flagName] = func() error { return set(*v) }
+	}
+}
+
+// String declares a string setting
+func (s *Settings) String(p *string, env, flagName, usage string) {
+	s.Var(env, flagName, usage, *p, func(v string) error {
+		*p = v
+		return nil
+	})
+}
+
+// Duration declares a duration setting, written such as "90s" or "1h"
+func (s *Settings) Duration(p *Duration, env, flagName, usage string) {
+	if env != "" {
+		s.env = append(s.env, envSetting{env, func(v string) error {
+			d, err := time.ParseDuration(v)
+			*p = Duration(d)
+			return err
+		}})
+	}
+	if flagName != "" {
+		v := s.flags.Duration(flagName, time.Duration(*p), usage)
+		s.flagSetters[flagName] = func() error {
+			*p = Duration(*v)
+			return nil
+		}
+	}
+}
+
+// List declares a setting holding a list, written separated by commas
+func (s *Settings) List(p *[]string, env, flagName, usage string) {
+	s.Var(env, flagName, usage, strings.Join(*p, ","), func(v string) error {
+		*p = SplitList(v)
+		return nil
+	})
+}
+
+// Load parses the command-line arguments and applies every source in turn.
+// It returns the arguments left after the flags.
+func (s *Settings) Load(args []string) ([]string, error) {
+	if err := s.flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// Config file
+	if *s.configPath != "" {
+		if err := s.readFile(*s.configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	// Environment
+	for _, e := range s.env {
+		if v, ok := os.LookupEnv(e.name); ok {
+			if err := e.set(v); err != nil {
+				return nil, fmt.Errorf("%s: %v", e.name, err)
+			}
+		}
+	}
+
+	// Flags that were set explicitly
+	var err error
+	s.flags.Visit(func(f *flag.Flag) {
+		if set, ok := s.flagSetters[f.Name]; ok && err == nil {
+			if setErr := set(); setErr != nil {
+				err = fmt.Errorf("flag -%s: %v", f.Name, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.flags.Args(), nil
+}
+
+// readFile overlays the service's section of the config file onto the
+// target
+func (s *Settings) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	if section, ok := sections[s.section]; ok {
+		if err := json.Unmarshal(section, s.target); err != nil {
+			return fmt.Errorf("parsing config file %s: %s: %w", path, s.section, err)
+		}
+	}
+	return nil
+}
+
+// SplitList splits a comma-separated list, dropping blank entries
+func SplitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// CheckAddr checks an address to listen on, such as ":9000"
+func CheckAddr(name, addr string) error {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("%s %q: %v", name, addr, err)
+	}
+	return nil
+}
+
+// CheckURL checks that *raw is an http or https URL and trims any trailing
+// slash, so paths can be appended to it
+func CheckURL(name string, raw *string) error {
+	u, err := url.Parse(*raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s %q must be an http or https URL", name, *raw)
+	}
+	*raw = strings.TrimSuffix(*raw, "/")
+	return nil
+}
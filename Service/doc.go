@@ -0,0 +1,5 @@
+// Package service holds the plumbing shared by the CloudCuisine services:
+// settings read from the shared config file, the environment and flags,
+// health checks, graceful shutdown, and the folding of ingredient names
+// that both services match on.
+package service
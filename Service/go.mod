@@ -0,0 +1,3 @@
+module Service
+
+go 1.22.2
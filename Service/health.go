@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// checkTimeout is how long the readiness checks may take together
+const checkTimeout = 2 * time.Second
+
+// Status is the response of the health endpoints
+type Status struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Check is one thing a service needs, such as its store or another service
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+	// Informational checks are reported, but don't make the service
+	// unready: it can still take requests without them
+	Informational bool
+}
+
+// Healthz reports that the process is up. It checks nothing else, so a
+// slow dependency doesn't get the service restarted.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, http.StatusOK, Status{Status: "ok"})
+}
+
+// Readyz returns a handler that reports whether the service can take
+// requests: every check that isn't informational has to pass, and the
+// service mustn't be draining
+func Readyz(draining *atomic.Bool, checks ...Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+
+		health := Status{Status: "ok", Checks: make(map[string]string)}
+		for _, c := range checks {
+			health.Checks[c.Name] = "ok"
+			if err := c.Run(ctx); err != nil {
+				health.Checks[c.Name] = err.Error()
+				if !c.Informational {
+					health.Status = "unavailable"
+				}
+			}
+		}
+		if draining.Load() {
+			health.Checks["shutdown"] = "shutting down"
+			health.Status = "unavailable"
+		}
+
+		status := http.StatusOK
+		if health.Status != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+		writeStatus(w, status, health)
+	}
+}
+
+// Upstream returns a check that calls the health check of the service at
+// baseURL
+func Upstream(baseURL string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/healthz", nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("health check returned %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+func writeStatus(w http.ResponseWriter, status int, health Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(health)
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeName folds an ingredient name for matching: lower case, no
+// punctuation, single spaces, and each word made singular, so "Tomatoes",
+// "tomato" and "Tomato " all normalize to "tomato". The services compare
+// names with it, so a name matches the same way everywhere.
+func NormalizeName(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+	for i, w := range words {
+		words[i] = Singular(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// ieWords are the words ending in "ie" whose plurals would otherwise be
+// read as ending in "y"
+var ieWords = map[string]bool{
+	"brownie":  true,
+	"calorie":  true,
+	"cookie":   true,
+	"hoagie":   true,
+	"smoothie": true,
+	"veggie":   true,
+}
+
+// Singular strips common English plural endings from a single word
+func Singular(w string) string {
+	switch {
+	case len(w) <= 3:
+		return w
+	case strings.HasSuffix(w, "ies"):
+		if len(w) <= 4 || ieWords[w[:len(w)-1]] {
+			return w[:len(w)-1] // pies, cookies
+		}
+		return w[:len(w)-3] + "y" // berries
+	case strings.HasSuffix(w, "oes"),
+		strings.HasSuffix(w, "ches"),
+		strings.HasSuffix(w, "shes"),
+		strings.HasSuffix(w, "sses"),
+		strings.HasSuffix(w, "xes"):
+		return w[:len(w)-2] // tomatoes, peaches, radishes, glasses, boxes
+	case strings.HasSuffix(w, "ss"), strings.HasSuffix(w, "us"), strings.HasSuffix(w, "is"):
+		return w // swiss, asparagus, pastis
+	case strings.HasSuffix(w, "s"):
+		return w[:len(w)-1]
+	}
+	return w
+}
@@ -0,0 +1,34 @@
+package service
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Tomatoes", "tomato"},
+		{"  Tomato ", "tomato"},
+		{"berries", "berry"},
+		{"Cherries", "cherry"},
+		{"cookies", "cookie"},
+		{"Chocolate Chip Cookies", "chocolate chip cookie"},
+		{"pies", "pie"},
+		{"brownies", "brownie"},
+		{"peaches", "peach"},
+		{"radishes", "radish"},
+		{"boxes", "box"},
+		{"glasses", "glass"},
+		{"swiss", "swiss"},
+		{"asparagus", "asparagus"},
+		{"eggs", "egg"},
+		{"peas", "pea"},
+		{"Salt & Pepper", "salt pepper"},
+		{"sun-dried tomatoes", "sun-dried tomato"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeName(tt.name); got != tt.want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
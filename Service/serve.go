@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ShutdownTimeout is how long in-flight requests get to finish on shutdown
+const ShutdownTimeout = 30 * time.Second
+
+// DefaultDrainGrace is how long a service keeps serving after it is told to
+// stop, unless it is configured otherwise
+const DefaultDrainGrace = 5 * time.Second
+
+// ListenAndServe runs server until the process gets SIGINT or SIGTERM.
+// It then sets draining, which fails the readiness check, and keeps serving
+// for grace so load balancers notice and stop sending requests. Only then
+// does it stop taking new connections and wait for the requests in flight.
+func ListenAndServe(server *http.Server, draining *atomic.Bool, grace time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	draining.Store(true)
+	if grace > 0 {
+		log.Printf("Shutting down in %s, reporting not ready meanwhile", grace)
+		select {
+		case err := <-errs:
+			return err
+		case <-time.After(grace):
+		}
+	}
+
+	log.Printf("Shutting down, waiting for requests in flight")
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
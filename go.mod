@@ -0,0 +1,7 @@
+module CloudCuisine
+
+go 1.22.2
+
+require Service v0.0.0-00010101000000-000000000000
+
+replace Service => ./Service
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"strconv"
+	"sync/atomic"
 	"text/template"
+	"time"
+
+	"Service"
 )
 
 // Recipe represents the JSON data structure
@@ -19,9 +28,95 @@ type Recipe struct {
 	PhotoURL           string   `json:"image"`
 	MealType           string   `json:"dishTypes"`
 	DietaryRestriction []string `json:"dietary_restriction"`
+	Steps              []Step   `json:"steps"`
+}
+
+// Step is one numbered step of a recipe's instructions, as the recipe
+// service returns them. Duration and MaxDuration are in seconds.
+type Step struct {
+	Number      int          `json:"number"`
+	Text        string       `json:"text"`
+	Duration    int          `json:"duration,omitempty"`
+	MaxDuration int          `json:"max_duration,omitempty"`
+	Temperature *Temperature `json:"temperature,omitempty"`
+}
+
+// Temperature is a temperature in the unit the recipe gives it in, "F" or
+// "C"
+type Temperature struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// webConfig holds the web frontend settings. Values are read, in increasing
+// order of precedence, from the defaults below, the "web" section of the
+// shared config file, environment variables and command-line flags.
+type webConfig struct {
+	Addr               string `json:"addr"`
+	RecipeServiceURL   string `json:"recipe_service_url"`
+	SpoonacularBaseURL string `json:"spoonacular_base_url"`
+	// PantryURL is where ingredient suggestions come from, asked for by
+	// the server with PantryToken if the pantry requires one. The token is
+	// never sent to the browser.
+	PantryURL   string `json:"pantry_url"`
+	PantryToken string `json:"pantry_token"`
+
+	// DrainGrace is how long the frontend keeps serving, while reporting
+	// not ready, after it is told to shut down
+	DrainGrace service.Duration `json:"drain_grace"`
+}
+
+var config = webConfig{
+	Addr:               ":8080",
+	RecipeServiceURL:   "http://localhost:8081",
+	SpoonacularBaseURL: "https://api.spoonacular.com",
+	PantryURL:          "http://localhost:9000",
+	DrainGrace:         service.Duration(service.DefaultDrainGrace),
+}
+
+// loadConfig fills in config from every source and validates it
+func loadConfig(args []string) error {
+	settings := service.NewSettings("web", "web", &config)
+	settings.String(&config.Addr, "WEB_ADDR", "addr", "address to listen on")
+	settings.String(&config.RecipeServiceURL, "RECIPE_SERVICE_URL", "recipe-service", "base URL of the recipe service")
+	settings.String(&config.SpoonacularBaseURL, "SPOONACULAR_BASE_URL", "spoonacular", "base URL of the Spoonacular API")
+	settings.String(&config.PantryURL, "WEB_PANTRY_URL", "pantry", "base URL of the pantry service, for ingredient suggestions")
+	settings.String(&config.PantryToken, "WEB_PANTRY_TOKEN", "", "")
+	settings.Duration(&config.DrainGrace, "WEB_DRAIN_GRACE", "drain-grace", "how long to keep serving, reporting not ready, before shutting down")
+	if _, err := settings.Load(args); err != nil {
+		return err
+	}
+
+	// Validate
+	var errs []error
+	if err := service.CheckAddr("addr", config.Addr); err != nil {
+		errs = append(errs, err)
+	}
+	if err := service.CheckURL("recipe_service_url", &config.RecipeServiceURL); err != nil {
+		errs = append(errs, err)
+	}
+	if err := service.CheckURL("spoonacular_base_url", &config.SpoonacularBaseURL); err != nil {
+		errs = append(errs, err)
+	}
+	if err := service.CheckURL("pantry_url", &config.PantryURL); err != nil {
+		errs = append(errs, err)
+	}
+	if config.DrainGrace < 0 {
+		errs = append(errs, errors.New("drain_grace must not be negative"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
 }
 
 func main() {
+	if err := loadConfig(os.Args[1:]); err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
 	// Define a handler function for the homepage
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "index.html")
@@ -42,10 +137,24 @@ func main() {
 	// Define a handler function for the recipe details page
 	http.HandleFunc("/api/", externalAPIHandler)
 
+	// Ingredient suggestions for the homepage, from the pantry
+	http.HandleFunc("/autocomplete", autocompleteHandler)
+
+	// Health checks. The pages need the recipe service.
+	http.HandleFunc("/healthz", service.Healthz)
+	http.HandleFunc("/readyz", service.Readyz(&draining,
+		service.Check{Name: "recipe_service", Run: service.Upstream(config.RecipeServiceURL)},
+	))
+
 	// Start the web server
-	http.ListenAndServe(":8080", nil)
+	if err := service.ListenAndServe(&http.Server{Addr: config.Addr}, &draining, time.Duration(config.DrainGrace)); err != nil {
+		log.Fatal(err)
+	}
 }
 
+// draining is set once the server has started shutting down
+var draining atomic.Bool
+
 // detailPageHandler is responsible for rendering the recipe details page using a template
 func detailPageHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse the recipe ID from the query parameters
@@ -54,7 +163,7 @@ func detailPageHandler(w http.ResponseWriter, r *http.Request) {
 
 	if call == "favorites" {
 		// Make a GET request to fetch the recipe details based on the ID
-		resp, err := http.Get(fmt.Sprintf("http://localhost:8081/details?id=%s", id))
+		resp, err := http.Get(fmt.Sprintf("%s/details?id=%s", config.RecipeServiceURL, id))
 		if err != nil {
 			http.Error(w, "Failed to fetch recipe details", http.StatusInternalServerError)
 			return
@@ -91,7 +200,7 @@ func detailPageHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Make a GET request to fetch the recipe details based on the ID
-		url := fmt.Sprintf("https://api.spoonacular.com/recipes/%s/information?apiKey=%s", id, apiKey)
+		url := fmt.Sprintf("%s/recipes/%s/information?apiKey=%s", config.SpoonacularBaseURL, id, apiKey)
 
 		// Make the GET request to the external API
 		resp, err := http.Get(url)
@@ -114,10 +223,11 @@ func detailPageHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Decode the JSON response into a Recipe struct
-		recipe, err := ParseRecipe(body)
+		// Convert the JSON response into a Recipe struct
+		recipe, err := convertRecipe(body)
 		if err != nil {
-			http.Error(w, "Error decoding JSON", http.StatusInternalServerError)
+			log.Printf("Converting recipe %s: %v", id, err)
+			http.Error(w, "Failed to convert recipe details", http.StatusInternalServerError)
 			return
 		}
 
@@ -134,7 +244,7 @@ func detailPageHandler(w http.ResponseWriter, r *http.Request) {
 func recipeBookHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Make a GET request to fetch the recipe book data
-	resp, err := http.Get("http://localhost:8081/recipe?")
+	resp, err := http.Get(config.RecipeServiceURL + "/recipe?")
 	if err != nil {
 		http.Error(w, "Failed to fetch recipe book data", http.StatusInternalServerError)
 		return
@@ -166,7 +276,7 @@ func recipeBookHandler(w http.ResponseWriter, r *http.Request) {
 
 func pantryPageHandler(w http.ResponseWriter, r *http.Request) {
 	// Make a GET request to fetch the pantry data
-	resp, err := http.Get("http://localhost:8081/pantry")
+	resp, err := http.Get(config.RecipeServiceURL + "/pantry")
 	if err != nil {
 		http.Error(w, "Failed to fetch pantry data", http.StatusInternalServerError)
 		return
@@ -197,59 +307,23 @@ func pantryPageHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func ParseRecipe(data []byte) (Recipe, error) {
-	var recipeData struct {
-		ID                  int      `json:"id"`
-		Title               string   `json:"title"`
-		PhotoURL            string   `json:"image"`
-		DishTypes           []string `json:"dishTypes"`
-		Vegetarian          bool     `json:"vegetarian"`
-		Vegan               bool     `json:"vegan"`
-		GlutenFree          bool     `json:"glutenFree"`
-		ExtendedIngredients []struct {
-			Name string `json:"name"`
-		} `json:"extendedIngredients"`
-		Instructions string `json:"instructions"`
-	}
-
-	err := json.Unmarshal(data, &recipeData)
+// convertRecipe has the recipe service convert a recipe from the
+// Spoonacular API, so its ingredients and steps are parsed the same way as
+// those of the stored recipes
+func convertRecipe(data []byte) (Recipe, error) {
+	resp, err := http.Post(config.RecipeServiceURL+"/convert/spoonacular", "application/json", bytes.NewReader(data))
 	if err != nil {
-		fmt.Println("Error decoding JSON:", err)
 		return Recipe{}, err
 	}
-
-	// Initialize a Recipe struct
-	recipe := Recipe{
-		ID:                 strconv.Itoa(recipeData.ID),
-		Title:              recipeData.Title,
-		Ingredients:        make([]string, len(recipeData.ExtendedIngredients)),
-		Instructions:       recipeData.Instructions,
-		PhotoURL:           recipeData.PhotoURL,
-		MealType:           "",
-		DietaryRestriction: make([]string, 0),
-	}
-
-	// Map extended ingredients names to Ingredients
-	for i, extIng := range recipeData.ExtendedIngredients {
-		recipe.Ingredients[i] = extIng.Name
-	}
-
-	// Add dietary restrictions if true
-	if recipeData.Vegetarian {
-		recipe.DietaryRestriction = append(recipe.DietaryRestriction, "vegetarian")
-	}
-	if recipeData.Vegan {
-		recipe.DietaryRestriction = append(recipe.DietaryRestriction, "vegan")
-	}
-	if recipeData.GlutenFree {
-		recipe.DietaryRestriction = append(recipe.DietaryRestriction, "gluten-free")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Recipe{}, fmt.Errorf("recipe service answered %s", resp.Status)
 	}
 
-	// Combine dish types into a single string
-	if len(recipeData.DishTypes) > 0 {
-		recipe.MealType = recipeData.DishTypes[0] // Just take the first one for now
+	var recipe Recipe
+	if err := json.NewDecoder(resp.Body).Decode(&recipe); err != nil {
+		return Recipe{}, err
 	}
-
 	return recipe, nil
 }
 
@@ -267,7 +341,7 @@ func externalAPIHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Construct the URL for the external API request
-	url := fmt.Sprintf("https://api.spoonacular.com/recipes/complexSearch?apiKey=%s&instructionsRequired=true&type=%s&diet=%s&includeIngredients=%s", apiKey, mealType, dietaryRestriction, ingredients)
+	url := fmt.Sprintf("%s/recipes/complexSearch?apiKey=%s&instructionsRequired=true&type=%s&diet=%s&includeIngredients=%s", config.SpoonacularBaseURL, apiKey, mealType, dietaryRestriction, ingredients)
 
 	// Make the GET request to the external API
 	resp, err := http.Get(url)
@@ -294,3 +368,44 @@ func externalAPIHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// autocompleteHandler passes the homepage's requests for ingredient
+// suggestions on to the pantry, adding the pantry token on the way, so the
+// browser never sees it
+func autocompleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Only pass on the parameters autocomplete takes
+	query := url.Values{}
+	for _, name := range []string{"q", "limit"} {
+		if v := r.URL.Query().Get(name); v != "" {
+			query.Set(name, v)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.PantryURL+"/autocomplete?"+query.Encode(), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if config.PantryToken != "" {
+		req.Header.Set("Authorization", "Bearer "+config.PantryToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		http.Error(w, "Failed to fetch suggestions from the pantry", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	// The pantry answers in JSON, errors included
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}